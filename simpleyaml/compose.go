@@ -0,0 +1,243 @@
+package simpleyaml
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Docker Compose keys
+const (
+	composeKeyServices = "services"
+	composeKeyExtends  = "extends"
+	composeKeyFile     = "file"
+	composeKeyService  = "service"
+)
+
+// composeDelimPerList is the delimiter of the Docker Compose lists merged by key.
+var composeDelimPerList = map[string]string{
+	"environment": "=",
+	"labels":      "=",
+	"args":        "=",
+	"annotations": "=",
+	"sysctls":     "=",
+	"extra_hosts": ":",
+}
+
+// composeListModes is the merge mode of the other Docker Compose lists.
+var composeListModes = map[string]uint{
+	"ports":          ListModeAppend,
+	"expose":         ListModeAppend,
+	"external_links": ListModeAppend,
+	"dns":            ListModeAppend,
+	"dns_search":     ListModeAppend,
+	"dns_opt":        ListModeAppend,
+	"tmpfs":          ListModeAppend,
+	"cap_add":        ListModeAppend,
+	"cap_drop":       ListModeAppend,
+	"security_opt":   ListModeAppend,
+	"env_file":       ListModeAppend,
+	"volumes":        ListModeMountTarget,
+	"devices":        ListModeMountTarget,
+	"command":        ListModeReplace,
+	"entrypoint":     ListModeReplace,
+	"test":           ListModeReplace,
+}
+
+// composeNormalizedPaths is the delimiter of the Docker Compose keys accepting
+// either a list or a mapping.
+var composeNormalizedPaths = map[string]string{
+	"services.*.environment":   "=",
+	"services.*.labels":        "=",
+	"services.*.annotations":   "=",
+	"services.*.sysctls":       "=",
+	"services.*.extra_hosts":   ":",
+	"services.*.build.args":    "=",
+	"services.*.build.labels":  "=",
+	"services.*.deploy.labels": "=",
+}
+
+// composeReplacedPaths is the Docker Compose keys replaced whatever their form
+// (string or list).
+var composeReplacedPaths = []string{
+	"services.*.command",
+	"services.*.entrypoint",
+	"services.*.healthcheck.test",
+}
+
+// applyComposePreset configures the given merger with the Docker Compose
+// override rules. The delimiters per list already set are kept.
+func applyComposePreset(ym *YamlMerger) {
+	for k, v := range composeDelimPerList {
+		if _, isSet := ym.dplMap[k]; !isSet {
+			ym.dplMap[k] = v
+		}
+	}
+
+	for k, v := range composeListModes {
+		ym.SetListMode(k, v)
+	}
+
+	for k, v := range composeNormalizedPaths {
+		ym.SetNormalizedPath(k, v)
+	}
+
+	for _, path := range composeReplacedPaths {
+		ym.SetPathConflictPolicy(path, PolicyOverlayWins)
+	}
+}
+
+// newComposeMerger returns a new YamlMerger configured with the Docker Compose
+// override rules.
+func newComposeMerger(yamls []*YamlNode, deletionToken string) *YamlMerger {
+	ym := NewMerger(yamls, deletionToken, make(map[string]string), false)
+
+	applyComposePreset(ym)
+
+	return ym
+}
+
+// ResolveComposeExtends applies the services extended by the services of the
+// given Docker Compose YAML (within or across files), then removes their
+// `extends` key.
+func ResolveComposeExtends(yaml *YamlNode) error {
+	services := TraverseFindChild(yaml, composeKeyServices)
+	if services == nil {
+		return nil
+	}
+
+	for _, service := range services.children {
+		err := resolveComposeService(service, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resolveComposeService applies recursively the service extended by the
+// given service.
+// chain Services being resolved (cycle detection). e.g.: common.yml#base
+func resolveComposeService(service *YamlNode, chain []string) error {
+	extends := TraverseFindChild(service, composeKeyExtends)
+	if extends == nil {
+		return nil
+	}
+
+	id := composeServiceID(service)
+	for _, chainID := range chain {
+		if chainID == id {
+			return fmt.Errorf("Extends error: cycle detected at `%s` (%s): %s",
+				NodePath(service), nodeLocation(extends),
+				strings.Join(append(chain, id), " -> "))
+		}
+	}
+	chain = append(chain, id)
+
+	base, err := findComposeBaseService(extends)
+	if err != nil {
+		return err
+	}
+
+	err = resolveComposeService(base, chain)
+	if err != nil {
+		return err
+	}
+
+	RemoveChildNode(extends)
+
+	// Merge the service over its base
+	baseRoot := CreateRootNode()
+	CopyNode(base, NewChildNode(&baseRoot))
+
+	serviceRoot := CreateRootNode()
+	CopyNode(service, NewChildNode(&serviceRoot))
+	baseRoot.children[0].name = service.name
+
+	// Partial: the directives apply to the next merges too
+	merger := newComposeMerger([]*YamlNode{&baseRoot, &serviceRoot}, "")
+	merger.partialMerge = true
+
+	merged, _, err := merger.Merge()
+	if err != nil {
+		return err
+	}
+
+	source, line := service.source, service.line
+	service.children = nil
+	CopyNode(merged.children[0], service)
+	service.source, service.line = source, line
+
+	return nil
+}
+
+// findComposeBaseService returns the service referenced by the given
+// `extends` node. e.g.: {file: common.yml, service: base} or base
+func findComposeBaseService(extends *YamlNode) (*YamlNode, error) {
+	var serviceName, filePath string
+
+	if extends.ntype == NodeTypeScalar {
+		serviceName = unquote(extends.values[0])
+	} else {
+		serviceNode := TraverseFindChild(extends, composeKeyService)
+		if serviceNode != nil && serviceNode.ntype == NodeTypeScalar {
+			serviceName = unquote(serviceNode.values[0])
+		}
+
+		fileNode := TraverseFindChild(extends, composeKeyFile)
+		if fileNode != nil && fileNode.ntype == NodeTypeScalar {
+			filePath = unquote(fileNode.values[0])
+		}
+	}
+
+	if serviceName == "" {
+		return nil, fmt.Errorf("Extends error: missing service at `%s` (%s)",
+			NodePath(extends), nodeLocation(extends))
+	}
+
+	// Same file by default
+	root := extends
+	for root.parent != nil {
+		root = root.parent
+	}
+
+	if filePath != "" {
+		if !filepath.IsAbs(filePath) {
+			// Relative to the extending file
+			filePath = filepath.Join(filepath.Dir(extends.source), filePath)
+		}
+
+		file, err := os.Open(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("Extends error at `%s` (%s): %s",
+				NodePath(extends), nodeLocation(extends), err)
+		}
+		defer file.Close()
+
+		root, err = NewParser(file).Parse()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	base := TraverseFindPath(root, composeKeyServices+TkPathSeparator+EscapePathName(serviceName))
+	if base == nil {
+		return nil, fmt.Errorf("Extends error: service `%s` not found in %s at `%s` (%s)",
+			serviceName, root.source, NodePath(extends), nodeLocation(extends))
+	}
+
+	return base, nil
+}
+
+// composeServiceID returns the identifier of the given service.
+// e.g.: /path/to/common.yml#base
+func composeServiceID(service *YamlNode) string {
+	absPath, err := filepath.Abs(service.source)
+	if err != nil {
+		absPath = service.source
+	}
+
+	return absPath + TkIncludePathDelim + service.name
+}
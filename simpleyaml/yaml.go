@@ -7,15 +7,77 @@ const (
 	TkComment      = "#"
 	TkStringDelim1 = "\""
 	TkStringDelim2 = "'"
+	TkTag          = "!"
+	TkListItem     = "-" // Name of the mapping items of a list
+
+	TkDocumentStart = "---"
+	TkDocumentEnd   = "..."
+
+	TkFlowMappingStart = "{"
+	TkFlowMappingEnd   = "}"
+	TkFlowSeparator    = ","
+)
+
+// YAML Tags
+const (
+	TagInclude = "!include"
+	TagRef     = "!ref"  // Copy of a merged node. e.g.: !ref services.php.url
+	TagFlat    = "!flat" // Document of flat dotted keys. e.g.: --- !flat
 )
 
+// Merge directive Tags
+const (
+	TagDelete   = "!delete"   // Delete the node
+	TagReplace  = "!replace"  // Replace the node (no deep merge)
+	TagAppend   = "!append"   // Append the list items
+	TagPrepend  = "!prepend"  // Prepend the list items
+	TagDefault  = "!default"  // Set the node only if absent
+	TagRequired = "!required" // Placeholder to be set by a later YAML. e.g.: !required "message"
+	TagFinal    = "!final"    // Refuse the overrides and deletions of the node
+	TagRename   = "!rename"   // Rename the node. e.g.: !rename newname
+	TagCopyFrom = "!copyFrom" // Copy the node from a merged path. e.g.: !copyFrom services.php
+	TagMoveTo   = "!moveTo"   // Move the node to a merged path. e.g.: !moveTo services.app
+	TagAdd      = "!add"      // Add to the number. e.g.: !add 2
+	TagMax      = "!max"      // Maximum of the numbers. e.g.: !max 512
+	TagMin      = "!min"      // Minimum of the numbers. e.g.: !min 30
+	TagConcat   = "!concat"   // Concatenate to the string. e.g.: !concat " --verbose"
+	TagMatch    = "!match"    // Merge into the siblings matching the key pattern. e.g.: "web-*": !match
+)
+
+// isDirectiveTag tells if the given tag is a merge directive
+// (i.e. not to be written).
+func isDirectiveTag(tag string) bool {
+	switch tag {
+	case TagDelete, TagReplace, TagAppend, TagPrepend, TagDefault, TagRequired, TagFinal,
+		TagRename, TagCopyFrom, TagMoveTo, TagAdd, TagMax, TagMin, TagConcat, TagMatch:
+		return true
+	}
+
+	return false
+}
+
 // YamlNode is a YAML node (duh)
 type YamlNode struct {
 	name     string
 	values   []string
+	tag      string // e.g.: !include
 	ntype    uint
 	children []*YamlNode // Slice of pointers to avoid pointer reset when appending (because of parent ref)
 	parent   *YamlNode
+	source   string        // File the node comes from
+	line     uint          // Line of the node in its source file
+	origin   string        // File including the source file ("" if not included)
+	conflict *YamlConflict // Three-way merge conflict (written with markers)
+
+	comments     []string                  // Comment lines before the node, trailing ones for a root node (raw parsing)
+	lineComment  string                    // Comment at the end of the node line, with its leading spaces (raw parsing). e.g.:   # comment
+	itemComments map[string]*valueComments // Comments of the list values [raw value => comments] (raw parsing)
+}
+
+// valueComments is the comments of a list value (raw parsing)
+type valueComments struct {
+	comments    []string // Comment lines before the value
+	lineComment string   // Comment at the end of the value line, with its leading spaces
 }
 
 // Node Types
@@ -60,11 +122,25 @@ func RemoveChildNode(child *YamlNode) {
 	child.parent.children = newChildren
 }
 
+// ReplaceNode replaces the content of the given node by a deep copy
+// of the given new node.
+func ReplaceNode(node *YamlNode, newNode *YamlNode) {
+	node.children = nil
+	CopyNode(newNode, node)
+}
+
 // CopyNode makes a deep copy of the given node.
 func CopyNode(node *YamlNode, destNode *YamlNode) {
 	destNode.name = node.name
 	destNode.ntype = node.ntype
+	destNode.tag = node.tag
 	destNode.values = node.values
+	destNode.source = node.source
+	destNode.line = node.line
+	destNode.origin = node.origin
+	destNode.comments = node.comments
+	destNode.lineComment = node.lineComment
+	destNode.itemComments = node.itemComments
 
 	c := len(node.children)
 
@@ -73,3 +149,49 @@ func CopyNode(node *YamlNode, destNode *YamlNode) {
 		CopyNode(node.children[i], childNode)
 	}
 }
+
+// Name returns the key of the node ("-" for a list item).
+func (node *YamlNode) Name() string {
+	return node.name
+}
+
+// Type returns the type of the node. e.g.: NodeTypeScalar
+func (node *YamlNode) Type() uint {
+	return node.ntype
+}
+
+// Tag returns the tag of the node. e.g.: !replace
+func (node *YamlNode) Tag() string {
+	return node.tag
+}
+
+// Values returns the raw values of the node (quotes included):
+// the scalar value, or the list values.
+func (node *YamlNode) Values() []string {
+	return node.values
+}
+
+// SetValues sets the raw values of the node (quotes included).
+func (node *YamlNode) SetValues(values ...string) {
+	node.values = values
+}
+
+// Children returns the child nodes (mapping children or list items).
+func (node *YamlNode) Children() []*YamlNode {
+	return node.children
+}
+
+// Parent returns the parent node (nil for the root node).
+func (node *YamlNode) Parent() *YamlNode {
+	return node.parent
+}
+
+// Source returns the file the node comes from.
+func (node *YamlNode) Source() string {
+	return node.source
+}
+
+// Line returns the line of the node in its source file.
+func (node *YamlNode) Line() uint {
+	return node.line
+}
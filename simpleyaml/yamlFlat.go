@@ -0,0 +1,79 @@
+package simpleyaml
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Flat files extensions. e.g.: prod.flat.yml
+var flatExtensions = []string{".flat.yml", ".flat.yaml"}
+
+// isFlatFile tells if the given file contains flat dotted keys (by extension).
+func isFlatFile(fileName string) bool {
+	for _, ext := range flatExtensions {
+		if strings.HasSuffix(fileName, ext) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ExpandFlatKeys expands the dotted keys of the given YAML root into nested
+// nodes. A dot can be escaped with a backslash.
+// e.g.: services.php.environment.DEBUG: "false"
+func ExpandFlatKeys(yaml *YamlNode) error {
+	if yaml.ntype != NodeTypeChildren {
+		return fmt.Errorf("Flat key error (%s): the root is not a mapping", nodeLocation(yaml))
+	}
+
+	flatChildren := yaml.children
+	yaml.children = nil
+
+	for _, flatChild := range flatChildren {
+		names := SplitPath(unquote(flatChild.name))
+		if len(names) == 0 {
+			return fmt.Errorf("Flat key error at `%s` (%s): empty key", flatChild.name, nodeLocation(flatChild))
+		}
+
+		for _, name := range names {
+			if name == "" {
+				return fmt.Errorf("Flat key error at `%s` (%s): empty key segment", flatChild.name, nodeLocation(flatChild))
+			}
+		}
+
+		parent := yaml
+
+		for _, name := range names[:len(names)-1] {
+			child := TraverseFindChild(parent, name)
+			if child == nil {
+				child = NewChildNode(parent)
+				child.name = name
+				child.ntype = NodeTypeChildren
+				child.source = flatChild.source
+				child.line = flatChild.line
+			}
+
+			if child.ntype != NodeTypeChildren {
+				return fmt.Errorf("Flat key error at `%s` (%s): `%s` is not a mapping",
+					flatChild.name, nodeLocation(flatChild), NodePath(child))
+			}
+
+			parent = child
+		}
+
+		name := names[len(names)-1]
+
+		existing := TraverseFindChild(parent, name)
+		if existing != nil {
+			return fmt.Errorf("Flat key error at `%s` (%s): `%s` already set (%s)",
+				flatChild.name, nodeLocation(flatChild), NodePath(existing), nodeLocation(existing))
+		}
+
+		child := NewChildNode(parent)
+		CopyNode(flatChild, child)
+		child.name = name
+	}
+
+	return nil
+}
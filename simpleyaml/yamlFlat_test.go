@@ -0,0 +1,54 @@
+package simpleyaml
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpandFlatKeys(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		output string
+		err    string
+	}{
+		{
+			name:   "keys expanded",
+			input:  "services.php.image: php\nservices.php.environment.DEBUG: \"false\"\nlabels.traefik\\.enable: true\n",
+			output: "services:\n  php:\n    image: php\n    environment:\n      DEBUG: \"false\"\nlabels:\n  traefik.enable: true\n",
+		},
+		{name: "empty key", input: "\"\": 1\n", err: "empty key"},
+		{name: "empty segment", input: "z..b: 1\n", err: "empty key segment"},
+		{name: "trailing dot", input: "z.: 1\n", err: "empty key segment"},
+		{name: "not a mapping", input: "a: 1\na.b: 2\n", err: "`a` is not a mapping"},
+		{name: "already set", input: "a.b: 1\na.b: 2\n", err: "`a.b` already set"},
+		{name: "list root", input: "- a.b\n", err: "the root is not a mapping"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			file := openTestFile(t, "input.yml", test.input)
+			defer file.Close()
+
+			yaml, err := NewRawParser(file).Parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = ExpandFlatKeys(yaml)
+			checkMergeResult(t, mergeTest{output: test.output, err: test.err}, err, func() string {
+				return writeYamlStream(t, yaml)
+			})
+		})
+	}
+}
+
+func TestFlatFile(t *testing.T) {
+	file := openTestFile(t, "ports.flat.yml", "- 80\n")
+	defer file.Close()
+
+	_, err := NewParser(file).Parse()
+	if err == nil || !strings.Contains(err.Error(), "Flat key error") {
+		t.Fatalf("expected a flat key error, got %v", err)
+	}
+}
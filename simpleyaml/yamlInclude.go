@@ -0,0 +1,131 @@
+package simpleyaml
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Include Tokens
+const (
+	TkIncludePathDelim = "#" // e.g.: !include fragments/common.yml#services.php
+)
+
+// resolveIncludes replaces recursively the !include nodes of the given node
+// by the content of the included file (or subtree).
+func (yp *YamlParser) resolveIncludes(node *YamlNode) error {
+	if node.tag == TagInclude {
+		return yp.includeNode(node)
+	}
+
+	c := len(node.children)
+
+	for i := 0; i < c; i++ {
+		err := yp.resolveIncludes(node.children[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// includeNode replaces the content of the given !include node.
+func (yp *YamlParser) includeNode(node *YamlNode) error {
+	if node.ntype != NodeTypeScalar {
+		return fmt.Errorf("Include error: missing file path at `%s` (%s)",
+			NodePath(node), nodeLocation(node))
+	}
+
+	split := strings.SplitN(unquote(node.values[0]), TkIncludePathDelim, 2)
+	filePath := split[0]
+
+	if !filepath.IsAbs(filePath) {
+		// Relative to the including file
+		filePath = filepath.Join(filepath.Dir(yp.source), filePath)
+	}
+
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return err
+	}
+
+	chain := yp.chain()
+
+	for _, includingPath := range chain {
+		if includingPath == absPath {
+			return fmt.Errorf("Include error: cycle detected at `%s` (%s): %s",
+				NodePath(node), nodeLocation(node),
+				strings.Join(append(chain, absPath), " -> "))
+		}
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("Include error at `%s` (%s): %s",
+			NodePath(node), nodeLocation(node), err)
+	}
+	defer file.Close()
+
+	parser := NewParser(file)
+	parser.includeChain = chain
+
+	included, err := parser.Parse()
+	if err != nil {
+		return err
+	}
+
+	if len(split) > 1 {
+		included = TraverseFindPath(included, split[1])
+		if included == nil {
+			return fmt.Errorf("Include error: path `%s` not found in %s at `%s` (%s)",
+				split[1], filePath, NodePath(node), nodeLocation(node))
+		}
+	}
+
+	// Keep the node name and position, replace its content
+	name, source, line := node.name, node.source, node.line
+	node.children = nil
+	CopyNode(included, node)
+	node.name, node.source, node.line = name, source, line
+	node.tag = ""
+
+	setOrigin(node, yp.source)
+
+	return nil
+}
+
+// setOrigin sets recursively the given file as the origin of the given node
+// descendants: the file they are included in.
+func setOrigin(node *YamlNode, file string) {
+	for _, child := range node.children {
+		child.origin = file
+		setOrigin(child, file)
+	}
+}
+
+// chain returns the include chain of the parser, ending with its own file.
+func (yp *YamlParser) chain() []string {
+	absPath, err := filepath.Abs(yp.source)
+	if err != nil {
+		absPath = yp.source
+	}
+
+	chain := make([]string, len(yp.includeChain), len(yp.includeChain)+1)
+	copy(chain, yp.includeChain)
+
+	return append(chain, absPath)
+}
+
+// unquote returns the given value without its string delimiters (if any).
+func unquote(value string) string {
+	c := len(value)
+
+	if c >= 2 && (value[0] == TkStringDelim1[0] || value[0] == TkStringDelim2[0]) &&
+		value[c-1] == value[0] {
+		return value[1 : c-1]
+	}
+
+	return value
+}
@@ -0,0 +1,122 @@
+package simpleyaml
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// parseIncludingFile writes the given files [relative path => content] into
+// a temporary directory, then parses the first given one.
+func parseIncludingFile(t *testing.T, main string, files map[string]string) (*YamlNode, error) {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	file, err := os.Open(filepath.Join(dir, main))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	return NewParser(file).Parse()
+}
+
+func TestInclude(t *testing.T) {
+	yaml, err := parseIncludingFile(t, "main.yml", map[string]string{
+		"main.yml": "services:\n" +
+			"  php: !include fragments/php.yml\n" +
+			"  db: !include \"fragments/all.yml#services.db\"\n",
+		"fragments/php.yml":    "image: php\nenv: !include common.yml\n",
+		"fragments/common.yml": "APP_ENV: prod\n",
+		"fragments/all.yml":    "services:\n  db:\n    image: mysql\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "services:\n" +
+		"  php:\n" +
+		"    image: php\n" +
+		"    env:\n" +
+		"      APP_ENV: prod\n" +
+		"  db:\n" +
+		"    image: mysql\n"
+
+	if output := writeYamlStream(t, yaml); output != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, output)
+	}
+
+	php := TraverseFindPath(yaml, "services.php")
+	if php.tag != "" || php.line != 2 || filepath.Base(php.source) != "main.yml" {
+		t.Fatalf("expected the include node position kept, got %s:%d", php.source, php.line)
+	}
+}
+
+func TestIncludeErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		err   string
+	}{
+		{
+			name: "self cycle",
+			files: map[string]string{
+				"main.yml": "a: !include main.yml\n",
+			},
+			err: "Include error: cycle detected at `a`",
+		},
+		{
+			name: "indirect cycle",
+			files: map[string]string{
+				"main.yml":  "a: !include frag1.yml\n",
+				"frag1.yml": "b: !include frag2.yml\n",
+				"frag2.yml": "c: !include main.yml\n",
+			},
+			err: "main.yml -> ",
+		},
+		{
+			name: "missing file",
+			files: map[string]string{
+				"main.yml": "a: !include missing.yml\n",
+			},
+			err: "Include error at `a`",
+		},
+		{
+			name: "missing path",
+			files: map[string]string{
+				"main.yml": "a: !include \"frag.yml#b.c\"\n",
+				"frag.yml": "b: 1\n",
+			},
+			err: "Include error: path `b.c` not found",
+		},
+		{
+			name: "no file path",
+			files: map[string]string{
+				"main.yml": "a: !include\n  b: 1\n",
+			},
+			err: "Include error: missing file path at `a`",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := parseIncludingFile(t, "main.yml", test.files)
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("expected error containing %q, got %v", test.err, err)
+			}
+		})
+	}
+}
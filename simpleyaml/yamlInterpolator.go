@@ -0,0 +1,345 @@
+package simpleyaml
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Interpolation Tokens
+const (
+	TkVarPrefix     = "$"
+	TkVarOpenBrace  = "{"
+	TkVarCloseBrace = "}"
+)
+
+// Interpolation operators (Docker Compose rules)
+const (
+	varOpNone          = ""
+	varOpDefaultEmpty  = ":-" // Default if unset or empty
+	varOpDefault       = "-"  // Default if unset
+	varOpRequiredEmpty = ":?" // Error if unset or empty
+	varOpRequired      = "?"  // Error if unset
+	varOpAltEmpty      = ":+" // Alternative if set and not empty
+	varOpAlt           = "+"  // Alternative if set
+)
+
+// varExpr is a parsed variable expression. e.g.: ${VAR:-default}
+type varExpr struct {
+	name string
+	op   string
+	arg  string // Default value, error message or alternative value
+}
+
+// YamlInterpolator is the struct for interpolating variables in YAML values
+type YamlInterpolator struct {
+	env map[string]string // Variables [name => value]
+}
+
+// NewInterpolator returns a new YamlInterpolator to resolve ${VAR} expressions.
+//
+// env Variables to use for the interpolation
+func NewInterpolator(env map[string]string) *YamlInterpolator {
+	yi := new(YamlInterpolator)
+
+	yi.env = env
+
+	return yi
+}
+
+// Interpolate resolves recursively the variables of the given node values.
+func (yi *YamlInterpolator) Interpolate(node *YamlNode) error {
+	c := len(node.values)
+
+	for i := 0; i < c; i++ {
+		value, err := interpolateString(node.values[i], yi.resolve)
+		if err != nil {
+			return fmt.Errorf("Interpolation error at `%s` (%s): %s",
+				NodePath(node), nodeLocation(node), err)
+		}
+
+		if value != node.values[i] {
+			// Values may be shared with the node it was copied from
+			values := make([]string, c)
+			copy(values, node.values)
+			values[i] = value
+			node.values = values
+		}
+	}
+
+	c = len(node.children)
+
+	for i := 0; i < c; i++ {
+		err := yi.Interpolate(node.children[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resolve returns the value of the given variable expression.
+func (yi *YamlInterpolator) resolve(expr *varExpr) (string, error) {
+	if expr.isReference() {
+		// Resolved against the merged YAML, kept as is
+		return TkVarPrefix + TkVarOpenBrace + expr.name + TkVarCloseBrace, nil
+	}
+
+	value, isSet := yi.env[expr.name]
+
+	switch expr.op {
+	case varOpDefaultEmpty:
+		if value == "" {
+			return interpolateString(expr.arg, yi.resolve)
+		}
+	case varOpDefault:
+		if !isSet {
+			return interpolateString(expr.arg, yi.resolve)
+		}
+	case varOpRequiredEmpty:
+		if value == "" {
+			return "", yi.requiredErr(expr)
+		}
+	case varOpRequired:
+		if !isSet {
+			return "", yi.requiredErr(expr)
+		}
+	case varOpAltEmpty:
+		if value == "" {
+			return "", nil
+		}
+		return interpolateString(expr.arg, yi.resolve)
+	case varOpAlt:
+		if !isSet {
+			return "", nil
+		}
+		return interpolateString(expr.arg, yi.resolve)
+	}
+
+	return value, nil
+}
+
+// requiredErr returns the error of a missing required variable.
+func (yi *YamlInterpolator) requiredErr(expr *varExpr) error {
+	msg, err := interpolateString(expr.arg, yi.resolve)
+	if err != nil {
+		return err
+	}
+
+	if msg == "" {
+		return fmt.Errorf("Required variable `%s` is missing a value", expr.name)
+	}
+
+	return fmt.Errorf("Required variable `%s` is missing a value: %s", expr.name, msg)
+}
+
+// interpolateString returns the given string with its variable expressions
+// replaced by the value returned by resolve.
+// "$$" is an escaped "$".
+func interpolateString(str string, resolve func(*varExpr) (string, error)) (string, error) {
+	if !strings.Contains(str, TkVarPrefix) {
+		return str, nil
+	}
+
+	var result strings.Builder
+	c := len(str)
+
+	for i := 0; i < c; i++ {
+		if str[i] != TkVarPrefix[0] || i == c-1 {
+			result.WriteByte(str[i])
+			continue
+		}
+
+		next := str[i+1]
+
+		if next == TkVarPrefix[0] {
+			// Escaped
+			result.WriteByte(next)
+			i++
+			continue
+		}
+
+		var expr *varExpr
+		var end int
+		var err error
+
+		if next == TkVarOpenBrace[0] {
+			end = findClosingBrace(str, i+2)
+			if end < 0 {
+				return "", fmt.Errorf("Unclosed variable `%s`", str[i:])
+			}
+
+			expr, err = parseVarExpr(str[i+2 : end])
+			if err != nil {
+				return "", err
+			}
+		} else if isVarNameChar(next, true) {
+			end = i + 1
+			for end+1 < c && isVarNameChar(str[end+1], false) {
+				end++
+			}
+
+			expr = &varExpr{name: str[i+1 : end+1]}
+		} else {
+			result.WriteByte(str[i])
+			continue
+		}
+
+		value, err := resolve(expr)
+		if err != nil {
+			return "", err
+		}
+
+		result.WriteString(value)
+		i = end
+	}
+
+	return result.String(), nil
+}
+
+// parseVarExpr returns the variable expression of the given braced content.
+// e.g.: VAR:-default
+func parseVarExpr(content string) (*varExpr, error) {
+	expr := new(varExpr)
+	c := len(content)
+	i := 0
+
+	if strings.HasPrefix(content, TkPathSeparator) {
+		// Reference to a merged value (no operator). e.g.: ${.versions.php}
+		expr.name = content
+		return expr, nil
+	}
+
+	for i < c && isVarNameChar(content[i], i == 0) {
+		i++
+	}
+
+	if i == 0 {
+		return nil, fmt.Errorf("Invalid interpolation format `${%s}`", content)
+	}
+
+	expr.name = content[:i]
+
+	if i == c {
+		return expr, nil
+	}
+
+	ops := []string{varOpDefaultEmpty, varOpRequiredEmpty, varOpAltEmpty,
+		varOpDefault, varOpRequired, varOpAlt}
+
+	for _, op := range ops {
+		if strings.HasPrefix(content[i:], op) {
+			expr.op = op
+			expr.arg = content[i+len(op):]
+			return expr, nil
+		}
+	}
+
+	return nil, fmt.Errorf("Invalid interpolation format `${%s}`", content)
+}
+
+// isReference tells if the expression references a merged value. e.g.: ${.registry}
+func (expr *varExpr) isReference() bool {
+	return strings.HasPrefix(expr.name, TkPathSeparator)
+}
+
+// findClosingBrace returns the index of the brace closing the expression
+// starting at the given index, -1 if not found.
+func findClosingBrace(str string, start int) int {
+	depth := 1
+	c := len(str)
+
+	for i := start; i < c; i++ {
+		switch str[i] {
+		case TkVarOpenBrace[0]:
+			depth++
+		case TkVarCloseBrace[0]:
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+// isVarNameChar tells if the given character is allowed in a variable name.
+func isVarNameChar(char byte, first bool) bool {
+	if char == '_' || (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z') {
+		return true
+	}
+
+	return !first && char >= '0' && char <= '9'
+}
+
+// ReadEnvFile returns the variables declared in the given .env file.
+// Format: KEY=VALUE lines, "#" comments and optional "export " prefix.
+func ReadEnvFile(file *os.File) (map[string]string, error) {
+	env := make(map[string]string)
+	reader := bufio.NewReader(file)
+	var line uint
+
+	for {
+		rawLine, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		line++
+		str := strings.TrimSpace(rawLine)
+		str = strings.TrimPrefix(str, "export ")
+
+		if str != "" && !strings.HasPrefix(str, TkComment) {
+			split := strings.SplitN(str, "=", 2)
+			key := strings.TrimSpace(split[0])
+
+			if len(split) < 2 || key == "" {
+				return nil, fmt.Errorf("Malformed env line `%s` (%s:%d)",
+					str, file.Name(), line)
+			}
+
+			env[key] = unquoteEnvValue(strings.TrimSpace(split[1]))
+		}
+
+		if err == io.EOF {
+			break
+		}
+	}
+
+	return env, nil
+}
+
+// unquoteEnvValue returns the given .env value without its quotes
+// or trailing comment.
+func unquoteEnvValue(value string) string {
+	c := len(value)
+
+	if c >= 2 && (value[0] == TkStringDelim1[0] || value[0] == TkStringDelim2[0]) &&
+		value[c-1] == value[0] {
+		return value[1 : c-1]
+	}
+
+	if i := strings.Index(value, " "+TkComment); i >= 0 {
+		return strings.TrimSpace(value[:i])
+	}
+
+	return value
+}
+
+// EnvironToMap returns the map of the given "KEY=VALUE" list. e.g.: os.Environ()
+func EnvironToMap(environ []string) map[string]string {
+	env := make(map[string]string)
+
+	for _, kv := range environ {
+		split := strings.SplitN(kv, "=", 2)
+		if len(split) == 2 {
+			env[split[0]] = split[1]
+		}
+	}
+
+	return env
+}
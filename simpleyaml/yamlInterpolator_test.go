@@ -0,0 +1,113 @@
+package simpleyaml
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInterpolate(t *testing.T) {
+	interpolator := NewInterpolator(map[string]string{"SET": "value", "EMPTY": ""})
+
+	tests := []struct {
+		value    string
+		expected string
+		err      string
+	}{
+		{value: "$SET and ${SET}", expected: "value and value"},
+		{value: "${UNSET}", expected: ""},
+		{value: "${UNSET:-default}", expected: "default"},
+		{value: "${EMPTY:-default}", expected: "default"},
+		{value: "${EMPTY-default}", expected: ""},
+		{value: "${UNSET-default}", expected: "default"},
+		{value: "${UNSET:-${SET}}", expected: "value"},
+		{value: "${SET:+alt}", expected: "alt"},
+		{value: "${EMPTY:+alt}", expected: ""},
+		{value: "${EMPTY+alt}", expected: "alt"},
+		{value: "${UNSET+alt}", expected: ""},
+		{value: "${EMPTY?}", expected: ""},
+		{value: "$$SET costs $$5", expected: "$SET costs $5"},
+		{value: "${.registry}/php", expected: "${.registry}/php"},
+		{value: "${UNSET?}", err: "Required variable `UNSET` is missing a value"},
+		{value: "${EMPTY:?set it}", err: "Required variable `EMPTY` is missing a value: set it"},
+		{value: "${SET", err: "Unclosed variable"},
+		{value: "${1VAR}", err: "Invalid interpolation format `${1VAR}`"},
+		{value: "${SET:x}", err: "Invalid interpolation format `${SET:x}`"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			yaml := CreateRootNode()
+			node := NewChildNode(&yaml)
+			node.name = "a"
+			node.ntype = NodeTypeScalar
+			node.values = []string{test.value}
+
+			err := interpolator.Interpolate(&yaml)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected error containing %q, got %v", test.err, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if node.values[0] != test.expected {
+				t.Fatalf("expected %q, got %q", test.expected, node.values[0])
+			}
+		})
+	}
+}
+
+func TestInterpolateSharedValues(t *testing.T) {
+	yaml := parseYaml(t, "input.yml", "a:\n  - ${SET}\n")
+	copied := new(YamlNode)
+	CopyNode(yaml, copied)
+
+	err := NewInterpolator(map[string]string{"SET": "value"}).Interpolate(copied)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if yaml.children[0].values[0] != "${SET}" || copied.children[0].values[0] != "value" {
+		t.Fatalf("expected the copy only interpolated, got %v and %v",
+			yaml.children[0].values, copied.children[0].values)
+	}
+}
+
+func TestReadEnvFile(t *testing.T) {
+	file := openTestFile(t, ".env", "# comment\n"+
+		"export A=1\n"+
+		"B = \"quoted # kept\"\n"+
+		"C='single'\n"+
+		"D=value # comment\n"+
+		"\n"+
+		"E=\n")
+	defer file.Close()
+
+	env, err := ReadEnvFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{"A": "1", "B": "quoted # kept", "C": "single", "D": "value", "E": ""}
+	if len(env) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, env)
+	}
+
+	for k, v := range expected {
+		if env[k] != v {
+			t.Fatalf("expected %s=%q, got %q", k, v, env[k])
+		}
+	}
+
+	malformed := openTestFile(t, "bad.env", "A=1\nNOVALUE\n")
+	defer malformed.Close()
+
+	_, err = ReadEnvFile(malformed)
+	if err == nil || !strings.Contains(err.Error(), "Malformed env line `NOVALUE`") {
+		t.Fatalf("expected a malformed line error, got %v", err)
+	}
+}
@@ -0,0 +1,307 @@
+package simpleyaml
+
+import (
+	"strings"
+)
+
+// YamlLayout is the source layout of raw parsed YAMLs (see NewRawParser),
+// to rewrite in place only the lines of the nodes changed since:
+// the indentation, flow styles and comments of the others are kept.
+type YamlLayout struct {
+	yamls  []*YamlNode
+	lines  []string                  // Source lines
+	nodes  map[*YamlNode]*layoutNode // Parsed nodes => layout
+	starts map[*YamlNode]int         // YAML => index of its first content line
+	ends   map[*YamlNode]int         // YAML => index of its last content line (trailing comments excluded)
+	indent uint                      // Spaces per indentation level of the source
+}
+
+// layoutNode is the source layout of a parsed node.
+type layoutNode struct {
+	original    *YamlNode // Copy of the node as parsed
+	start       int       // Index of its first line (comments included)
+	end         int       // Index of its last line (descendants included)
+	header      int       // Index of its last line before its children (-1 if inline children)
+	column      int       // Column of its key, or of its "-" token for a list item
+	childColumn int       // Column of its children
+	inline      bool      // On the line of its parent. e.g.: {a: 1} or - name: x
+	next        int       // Index of the next line node not descendant (document order)
+}
+
+// NewLayout returns the layout of the given raw parsed YAMLs of the given source content.
+func NewLayout(yamls []*YamlNode, content string) *YamlLayout {
+	layout := new(YamlLayout)
+	layout.yamls = yamls
+	layout.lines = strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	layout.nodes = make(map[*YamlNode]*layoutNode)
+	layout.starts = make(map[*YamlNode]int)
+	layout.ends = make(map[*YamlNode]int)
+	layout.indent = OutputIndent
+
+	cursor := 0
+	for _, yaml := range yamls {
+		var nodes []*layoutNode
+		layout.collectNodes(yaml, new(YamlNode), &nodes)
+
+		if len(nodes) == 0 {
+			// Nothing to rewrite in place, new nodes follow the document start
+			if cursor < len(layout.lines) && isDocumentStart(layout.lines[cursor]) {
+				cursor++
+			}
+
+			layout.starts[yaml] = cursor
+			layout.ends[yaml] = cursor - 1
+			continue
+		}
+
+		// Content ends before the trailing comments of the document
+		end := len(layout.lines)
+		for i := nodes[len(nodes)-1].start + 1; i < len(layout.lines); i++ {
+			if isDocumentStart(layout.lines[i]) {
+				end = i
+				break
+			}
+		}
+		end -= len(yaml.comments) + 1
+
+		layout.starts[yaml] = nodes[0].start
+		layout.ends[yaml] = end
+
+		for _, node := range nodes {
+			node.end = end
+			if node.next < len(nodes) {
+				node.end = nodes[node.next].start - 1
+			}
+		}
+
+		cursor = end + 1
+	}
+
+	layout.detectIndent()
+
+	return layout
+}
+
+// collectNodes records recursively the layout of the descendants of the given
+// node (copied into the given snapshot), adding the ones starting a line to
+// the given list (document order).
+func (layout *YamlLayout) collectNodes(node *YamlNode, snapshot *YamlNode, nodes *[]*layoutNode) {
+	copyNodeContent(node, snapshot)
+
+	for _, child := range node.children {
+		info := &layoutNode{original: NewChildNode(snapshot), header: -1}
+		info.inline = child.line == 0 || int(child.line) > len(layout.lines) ||
+			(node.parent != nil && child.line == node.line)
+
+		if !info.inline {
+			line := layout.lines[child.line-1]
+			info.column = len(line) - len(strings.TrimLeft(line, " "))
+			info.start = int(child.line) - 1 - len(child.comments)
+
+			*nodes = append(*nodes, info)
+		}
+
+		layout.nodes[child] = info
+		layout.collectNodes(child, info.original, nodes)
+
+		if !info.inline {
+			info.next = len(*nodes)
+
+			if len(child.children) > 0 && !layout.nodes[child.children[0]].inline {
+				firstChild := layout.nodes[child.children[0]]
+				info.header = firstChild.start - 1
+				info.childColumn = firstChild.column
+			}
+		}
+	}
+}
+
+// copyNodeContent copies the given node content (not its children).
+func copyNodeContent(node *YamlNode, destNode *YamlNode) {
+	destNode.name = node.name
+	destNode.ntype = node.ntype
+	destNode.tag = node.tag
+	destNode.values = append([]string(nil), node.values...)
+	destNode.source = node.source
+	destNode.line = node.line
+	destNode.comments = node.comments
+	destNode.lineComment = node.lineComment
+	destNode.itemComments = node.itemComments
+}
+
+// detectIndent sets the indentation of the source: the smallest column
+// difference between a mapping key and its first child key.
+func (layout *YamlLayout) detectIndent() {
+	indent := 0
+
+	for node, info := range layout.nodes {
+		if info.header < 0 || node.ntype != NodeTypeChildren {
+			continue
+		}
+
+		diff := info.childColumn - info.column
+		if diff > 0 && (indent == 0 || diff < indent) {
+			indent = diff
+		}
+	}
+
+	if indent > 0 {
+		layout.indent = uint(indent)
+	}
+}
+
+// isDocumentStart tells if the given line starts a new document. e.g.: --- !delete
+func isDocumentStart(line string) bool {
+	line = strings.TrimRight(line, " \r")
+
+	return line == TkDocumentStart || strings.HasPrefix(line, TkDocumentStart+" ")
+}
+
+// WriteLayout writes in place the YAMLs of the given layout: the lines of the
+// nodes unchanged are copied as is, the other nodes are formatted with the
+// indentation of the source.
+func (yw *YamlWriter) WriteLayout(layout *YamlLayout) {
+	yw.indent = layout.indent
+
+	cursor := 0
+	for _, yaml := range layout.yamls {
+		yw.writeLines(layout.lines[cursor:layout.starts[yaml]], 0)
+
+		for _, child := range yaml.children {
+			yw.writeLayoutNode(layout, child, 0)
+		}
+
+		cursor = layout.ends[yaml] + 1
+	}
+
+	yw.writeLines(layout.lines[cursor:], 0)
+}
+
+// writeLayoutNode writes the given node at the given column: its source lines
+// if unchanged, the source lines of its key then its children if only its
+// children (or name) changed, formatted otherwise.
+func (yw *YamlWriter) writeLayoutNode(layout *YamlLayout, node *YamlNode, column int) {
+	info := layout.nodes[node]
+
+	if info != nil && !info.inline {
+		shift := column - info.column
+
+		if sameNodes(node, info.original) {
+			lines := renameKey(layout.lines[info.start:info.end+1], info, node.name)
+			if lines != nil {
+				yw.writeLines(lines, shift)
+				return
+			}
+		}
+
+		if info.header >= 0 && sameNodeHeaders(node, info.original) {
+			lines := renameKey(layout.lines[info.start:info.header+1], info, node.name)
+			if lines != nil {
+				yw.writeLines(lines, shift)
+
+				for _, child := range node.children {
+					yw.writeLayoutNode(layout, child, info.childColumn+shift)
+				}
+
+				return
+			}
+		}
+	}
+
+	margin := yw.margin
+	yw.margin = strings.Repeat(" ", column)
+	defer func() { yw.margin = margin }()
+
+	if isListItem(node) {
+		yw.writeListItem(node, 0)
+	} else {
+		yw.writeNode(node, 0)
+	}
+}
+
+// writeLines writes the given source lines, shifted by the given number of columns.
+func (yw *YamlWriter) writeLines(lines []string, shift int) {
+	for _, line := range lines {
+		if shift > 0 && line != "" {
+			line = strings.Repeat(" ", shift) + line
+		} else if shift < 0 {
+			trimmed := strings.TrimLeft(line, " ")
+			if len(line)-len(trimmed) >= -shift {
+				trimmed = line[-shift:]
+			}
+
+			line = trimmed
+		}
+
+		yw.file.Write([]byte(line + "\n"))
+	}
+}
+
+// renameKey returns the given source lines of the given node, its key renamed
+// to the given name (nil if the key is not found).
+func renameKey(lines []string, info *layoutNode, name string) []string {
+	if name == info.original.name {
+		return lines
+	}
+
+	i := int(info.original.line) - 1 - info.start
+	key := info.original.name + TkPostKey
+	if i >= len(lines) || len(lines[i]) < info.column || !strings.HasPrefix(lines[i][info.column:], key) {
+		return nil
+	}
+
+	renamed := append([]string(nil), lines...)
+	renamed[i] = lines[i][:info.column] + name + lines[i][info.column+len(info.original.name):]
+
+	return renamed
+}
+
+// sameNodes tells if the given nodes have the same content (whatever their name).
+func sameNodes(node1 *YamlNode, node2 *YamlNode) bool {
+	if !sameNodeHeaders(node1, node2) || len(node1.children) != len(node2.children) {
+		return false
+	}
+
+	for i, child := range node1.children {
+		if child.name != node2.children[i].name || !sameNodes(child, node2.children[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// sameNodeHeaders tells if the given nodes have the same content, their
+// children apart (whatever their name).
+func sameNodeHeaders(node1 *YamlNode, node2 *YamlNode) bool {
+	if node1.tag != node2.tag || node1.ntype != node2.ntype || node1.lineComment != node2.lineComment ||
+		!sameStrings(node1.values, node2.values) || !sameStrings(node1.comments, node2.comments) ||
+		len(node1.itemComments) != len(node2.itemComments) {
+		return false
+	}
+
+	for value, comments := range node1.itemComments {
+		comments2 := node2.itemComments[value]
+		if comments2 == nil || comments.lineComment != comments2.lineComment ||
+			!sameStrings(comments.comments, comments2.comments) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// sameStrings tells if the given string slices are equal.
+func sameStrings(values1 []string, values2 []string) bool {
+	if len(values1) != len(values2) {
+		return false
+	}
+
+	for i, value := range values1 {
+		if value != values2[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -1,7 +1,8 @@
 package simpleyaml
 
 import (
-	"errors"
+	"fmt"
+	"strings"
 	"unsafe"
 )
 
@@ -18,7 +19,35 @@ type YamlMerger struct {
 	delTk       string                    // Deletion token
 	dplMap      map[string]string         // Delimiter Per List map [list name => delimiter]
 	mappedLists map[int]map[string]string // Mapped lists of the base file [node pointer => mapped list]
+	listModes   map[string]uint           // Merge mode per list [list name => mode]
 	strictMode  bool                      // Merge in strict mode
+
+	conflictPolicy uint               // Global type conflict policy
+	pathPolicies   []pathPolicy       // Type conflict policies per path pattern
+	diagnostics    []*MergeDiagnostic // Non-fatal issues found during the merge
+
+	normalizedPaths []normalizedPath // Paths accepting either a list or a mapping
+
+	mergeKeys      map[string][]string // Keys of the lists mapping items [list name => keys]
+	strategicMerge bool                // Apply Kubernetes strategic merge patch directives
+	elementOrders  []*elementOrder     // Pending $setElementOrder directives
+
+	pathStrategies []pathStrategy // Custom merge strategies per path pattern
+
+	patternDepth uint // Number of pattern keys being merged
+
+	partialMerge bool // Intermediate merge (required placeholders may be set later)
+
+	finalPaths []string // Path patterns of the nodes overlays may not override
+
+	accessPolicies   []*AccessPolicy // Paths the overlays may touch
+	accessViolations []string        // Paths touched against the access policies
+
+	transformer *YamlTransformer // Transform tags applied to the merged YAML
+
+	profiles []string // Profiles folded in. e.g.: prod
+
+	mountPaths map[int]string // Paths the YAMLs roots are merged under [YAML index => path]
 }
 
 // NewMerger returns a new YamlMerger to merge X YAMLs.
@@ -44,52 +73,229 @@ func NewMerger(
 	ym.delTk = deletionToken
 	ym.dplMap = delimPerListMap
 	ym.mappedLists = make(map[int]map[string]string)
+	ym.listModes = make(map[string]uint)
+	ym.mergeKeys = make(map[string][]string)
+	ym.mountPaths = make(map[int]string)
 	ym.strictMode = strictMode
 
+	ym.conflictPolicy = PolicyOverlayWins
+	if strictMode {
+		ym.conflictPolicy = PolicyError
+	}
+
 	return ym
 }
 
-// Merge returns the merged YAML.
-func (ym *YamlMerger) Merge() (*YamlNode, error) {
+// SetListMode sets the merge mode of the lists with the given name.
+// It takes precedence over the delimiter per list.
+func (ym *YamlMerger) SetListMode(listName string, mode uint) {
+	ym.listModes[listName] = mode
+}
+
+// Merge returns the merged YAML and the diagnostics found during the merge.
+func (ym *YamlMerger) Merge() (*YamlNode, []*MergeDiagnostic, error) {
 	c := len(ym.yamls)
 
+	var err error
+	if !ym.partialMerge {
+		ym.finalYaml, err = ym.prepareYaml(0, ym.finalYaml)
+		if err != nil {
+			return nil, ym.diagnostics, err
+		}
+	}
+
 	for i := 1; i < c; i++ {
-		childX := TraverseDown(ym.yamls[i])
+		yaml := ym.yamls[i]
+		if !ym.partialMerge {
+			yaml, err = ym.prepareYaml(i, yaml)
+			if err != nil {
+				return nil, ym.diagnostics, err
+			}
+		}
+
+		childX := TraverseDown(yaml)
+		if childX == nil {
+			// Empty YAML
+			continue
+		}
 
 		err := ym.mergeNodes(ym.finalYaml, childX)
 		if err != nil {
-			return nil, err
+			return nil, ym.diagnostics, err
 		}
+
+		ym.applyElementOrders()
+	}
+
+	err = ym.accessError()
+	if err != nil {
+		return nil, ym.diagnostics, err
+	}
+
+	if ym.partialMerge {
+		return ym.finalYaml, ym.diagnostics, nil
+	}
+
+	err = ym.finalize(ym.finalYaml)
+	if err != nil {
+		return nil, ym.diagnostics, err
 	}
 
-	return ym.finalYaml, nil
+	return ym.finalYaml, ym.diagnostics, nil
+}
+
+// prepareYaml returns the YAML of the given index ready to be merged: its
+// profiles folded in, under its mount path.
+func (ym *YamlMerger) prepareYaml(index int, yaml *YamlNode) (*YamlNode, error) {
+	yaml, err := ym.foldProfiles(yaml)
+	if err != nil {
+		return nil, err
+	}
+
+	return ym.mountYaml(index, yaml), nil
+}
+
+// finalize completes the given merged YAML: checks that its required
+// placeholders are set, removes its merge directives, resolves its references
+// and applies its transform tags.
+func (ym *YamlMerger) finalize(yaml *YamlNode) error {
+	err := ym.checkRequired(yaml)
+	if err != nil {
+		return err
+	}
+
+	ym.removeDirectives(yaml)
+
+	err = ResolveReferences(yaml)
+	if err != nil || ym.transformer == nil {
+		return err
+	}
+
+	return ym.transformer.Transform(yaml)
 }
 
 // mergeNodes merges recursively the childX node into the parent0 node.
 func (ym *YamlMerger) mergeNodes(parent0 *YamlNode, childX *YamlNode) error {
 	child0 := TraverseFindChild(parent0, childX.name)
 
+	if childX.tag == TagMatch {
+		return ym.mergePatternKey(parent0, childX)
+	}
+
+	if len(ym.accessPolicies) > 0 {
+		ym.checkAccess(parent0, child0, childX)
+	}
+
+	unchanged, err := ym.checkFinal(parent0, child0, childX)
+	if err != nil {
+		return err
+	}
+
+	if unchanged {
+		// Final node restated as is
+		return ym.mergeNextNode(parent0, childX)
+	}
+
+	if ym.strategicMerge && strings.HasPrefix(childX.name, tkSetElementOrder) {
+		ym.addElementOrder(parent0, childX)
+
+		return ym.mergeNextNode(parent0, childX)
+	}
+
+	if ym.strategicMerge && strings.HasPrefix(childX.name, tkDeleteFromList) {
+		ym.deleteFromPrimitiveList(parent0, childX)
+
+		return ym.mergeNextNode(parent0, childX)
+	}
+
+	if isMoveTag(childX.tag) {
+		return ym.mergeMoveDirective(parent0, child0, childX)
+	}
+
+	if child0 != nil && isOperatorTag(childX.tag) {
+		err = mergeOperator(child0, childX)
+		if err != nil {
+			return err
+		}
+
+		return ym.mergeNextNode(parent0, childX)
+	}
+
 	if child0 == nil {
-		// Matching node not found in parent0, append childX
-		newChild0 := NewChildNode(parent0)
-		CopyNode(childX, newChild0)
+		if isOperatorTag(childX.tag) {
+			err = checkOperand(childX)
+			if err != nil {
+				return err
+			}
+		}
+
+		if childX.tag != TagDelete && !(ym.strategicMerge && nodePatch(childX) == patchDelete) &&
+			!(ym.patternDepth > 0 && childX.ntype == NodeTypeScalar && childX.values[0] == ym.delTk) {
+			// Matching node not found in parent0, append childX
+			newChild0 := NewChildNode(parent0)
+			CopyNode(childX, newChild0)
+		}
 
 		return ym.mergeNextNode(parent0, childX)
 	}
 
-	if childX.ntype == NodeTypeScalar && childX.values[0] == ym.delTk {
-		// Deletion token as value, remove node
+	if childX.tag == TagDelete ||
+		(childX.ntype == NodeTypeScalar && childX.values[0] == ym.delTk) {
+		// Deletion tag or token as value, remove node
 		RemoveChildNode(child0)
 
 		return ym.mergeNextNode(parent0, childX)
 	}
 
+	if childX.tag == TagDefault || childX.tag == TagRequired {
+		// Already set
+		return ym.mergeNextNode(parent0, childX)
+	}
+
+	if childX.tag == TagReplace || child0.tag == TagRequired {
+		// Replaced whatever its type, the placeholder is filled
+		delete(ym.mappedLists, nodePointerToInt(child0))
+		ReplaceNode(child0, childX)
+
+		return ym.mergeNextNode(parent0, childX)
+	}
+
+	if len(ym.pathStrategies) > 0 {
+		merged, err := ym.mergeStrategy(child0, childX)
+		if err != nil {
+			return err
+		}
+
+		if merged {
+			return ym.mergeNextNode(parent0, childX)
+		}
+	}
+
+	if ym.strategicMerge && childX.ntype == NodeTypeChildren && ym.mergeNodesPatch(child0, childX) {
+		return ym.mergeNextNode(parent0, childX)
+	}
+
+	if len(ym.normalizedPaths) > 0 && isNormalizable(child0) && isNormalizable(childX) {
+		delim, isNormalized := ym.normalizedDelimFor(NodePath(child0))
+		if isNormalized {
+			return ym.mergeNormalized(parent0, child0, childX, delim)
+		}
+	}
+
 	if child0.ntype != childX.ntype {
 		return ym.mergeDifferentNodeType(parent0, child0, childX)
 	}
 
 	if childX.ntype == NodeTypeScalar {
 		child0.values = []string{childX.values[0]}
+		child0.tag = childX.tag
+		child0.source = childX.source
+		child0.line = childX.line
+	} else if hasListItems(child0) || hasListItems(childX) {
+		err := ym.mergeListItems(child0, childX)
+		if err != nil {
+			return err
+		}
 	} else if childX.ntype == NodeTypeList {
 		err := ym.mergeNodesList(child0, childX)
 		if err != nil {
@@ -115,10 +321,34 @@ func (ym *YamlMerger) mergeDifferentNodeType(
 	child0 *YamlNode,
 	childX *YamlNode,
 ) error {
-	if ym.strictMode {
-		err := errors.New("Fatal error: [Strict Mode] Different node type found: " +
-			child0.name + " / " + childX.name)
-		return err
+	path := NodePath(child0)
+
+	switch ym.conflictPolicyFor(path) {
+	case PolicyError:
+		mode := ""
+		if ym.strictMode {
+			mode = "[Strict Mode] "
+		}
+
+		return fmt.Errorf("Fatal error: %sDifferent node type found: %s / %s at `%s` (%s)",
+			mode, child0.name, childX.name, path, nodeLocation(childX))
+	case PolicyBaseWins:
+		return ym.mergeNextNode(parent0, childX)
+	case PolicyWrapScalarIntoList:
+		wrapped, err := ym.wrapScalarIntoList(child0, childX)
+		if err != nil {
+			return err
+		}
+
+		if !wrapped {
+			return fmt.Errorf("Fatal error: Different node type found: %s / %s at `%s` (%s), "+
+				"only a scalar and a list can be wrapped", child0.name, childX.name, path,
+				nodeLocation(childX))
+		}
+
+		return ym.mergeNextNode(parent0, childX)
+	case PolicyWarn:
+		ym.diagnose(path, childX, "Different node type found, overwritten by overlay")
 	}
 
 	if child0.ntype == NodeTypeList {
@@ -127,7 +357,7 @@ func (ym *YamlMerger) mergeDifferentNodeType(
 	}
 
 	// Overwrite child0 with childX
-	CopyNode(childX, child0)
+	ReplaceNode(child0, childX)
 
 	return ym.mergeNextNode(parent0, childX)
 }
@@ -150,9 +380,30 @@ func (ym *YamlMerger) mergeNextNode(parent0 *YamlNode, childX *YamlNode) error {
 	return ym.mergeNodes(nextParent0, nextNodeX)
 }
 
+// removeDirectives removes recursively the merge directives left in the given
+// node: nodes tagged for deletion are removed, other directive tags are cleared.
+func (ym *YamlMerger) removeDirectives(node *YamlNode) {
+	var children []*YamlNode
+
+	for _, child := range node.children {
+		if child.tag == TagDelete || (ym.strategicMerge && isStrategicDirective(child)) {
+			continue
+		}
+
+		if isDirectiveTag(child.tag) {
+			child.tag = ""
+		}
+
+		ym.removeDirectives(child)
+		children = append(children, child)
+	}
+
+	node.children = children
+}
+
 // nodePointerToInt returns the pointer casted to integer for the given node.
 func nodePointerToInt(node *YamlNode) int {
 	ptr := unsafe.Pointer(node)
 
-	return *((*int)(ptr))
+	return int(uintptr(ptr))
 }
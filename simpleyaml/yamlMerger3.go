@@ -0,0 +1,328 @@
+package simpleyaml
+
+// Conflict markers Tokens
+const (
+	TkConflictOurs   = "<<<<<<< ours"
+	TkConflictSep    = "======="
+	TkConflictTheirs = ">>>>>>> theirs"
+)
+
+// YamlConflict is a node changed differently by both sides of a three-way merge.
+type YamlConflict struct {
+	Path     string
+	Document int       // Index of the document (multi-documents files)
+	Base     *YamlNode // nil if absent
+	Ours     *YamlNode // nil if deleted
+	Theirs   *YamlNode // nil if deleted
+}
+
+// YamlMerger3 is the struct for merging YAML files with a common ancestor
+type YamlMerger3 struct {
+	base      *YamlNode
+	ours      *YamlNode
+	theirs    *YamlNode
+	markers   bool // Write the conflicts with markers (otherwise ours is kept)
+	conflicts []*YamlConflict
+}
+
+// NewMerger3 returns a new YamlMerger3 to merge the changes of two YAMLs
+// from their common ancestor.
+//
+// base     Common ancestor (usually root node)
+// ours     Our version
+// theirs   Their version
+// markers  Write the conflicts with markers (otherwise ours is kept)
+func NewMerger3(base *YamlNode, ours *YamlNode, theirs *YamlNode, markers bool) *YamlMerger3 {
+	ym3 := new(YamlMerger3)
+
+	ym3.base = base
+	ym3.ours = ours
+	ym3.theirs = theirs
+	ym3.markers = markers
+
+	return ym3
+}
+
+// MergeStream3 returns the merged documents of the given streams (matched by
+// position) and the conflicts found. A document removed on one side and
+// unchanged on the other is removed.
+//
+// markers  Write the conflicts with markers (otherwise ours is kept)
+func MergeStream3(base []*YamlNode, ours []*YamlNode, theirs []*YamlNode, markers bool) ([]*YamlNode, []*YamlConflict) {
+	var documents []*YamlNode
+	var conflicts []*YamlConflict
+
+	c := len(base)
+	if len(ours) > c {
+		c = len(ours)
+	}
+	if len(theirs) > c {
+		c = len(theirs)
+	}
+
+	for i := 0; i < c; i++ {
+		base0, ours0, theirs0 := documentAt(base, i), documentAt(ours, i), documentAt(theirs, i)
+
+		if (ours0 == nil && NodesEqual(base0, theirs0)) || (theirs0 == nil && NodesEqual(base0, ours0)) {
+			// Removed
+			continue
+		}
+
+		merged, documentConflicts := NewMerger3(
+			orEmptyDocument(base0), orEmptyDocument(ours0), orEmptyDocument(theirs0), markers).Merge()
+
+		for _, conflict := range documentConflicts {
+			conflict.Document = i
+		}
+
+		documents = append(documents, merged)
+		conflicts = append(conflicts, documentConflicts...)
+	}
+
+	return documents, conflicts
+}
+
+// documentAt returns the document of the given index, nil if absent.
+func documentAt(documents []*YamlNode, index int) *YamlNode {
+	if index >= len(documents) {
+		return nil
+	}
+
+	return documents[index]
+}
+
+// orEmptyDocument returns the given document, or an empty one if nil.
+func orEmptyDocument(document *YamlNode) *YamlNode {
+	if document == nil {
+		rootNode := CreateRootNode()
+		return &rootNode
+	}
+
+	return document
+}
+
+// Merge returns the merged YAML and the conflicts found.
+func (ym3 *YamlMerger3) Merge() (*YamlNode, []*YamlConflict) {
+	rootNode := CreateRootNode()
+	rootNode.source = ym3.ours.source
+	rootNode.tag = ym3.ours.tag
+	rootNode.comments = ym3.ours.comments
+
+	ym3.mergeChildren(&rootNode, ym3.base, ym3.ours, ym3.theirs)
+
+	return &rootNode, ym3.conflicts
+}
+
+// mergeChildren merges the children of the given mappings into the given node.
+// Ours order is kept, their new children are appended.
+func (ym3 *YamlMerger3) mergeChildren(node *YamlNode, base *YamlNode, ours *YamlNode, theirs *YamlNode) {
+	var names []string
+	seen := make(map[string]bool)
+
+	for _, parent := range []*YamlNode{ours, theirs, base} {
+		if parent == nil {
+			continue
+		}
+
+		for _, child := range parent.children {
+			if !seen[child.name] {
+				seen[child.name] = true
+				names = append(names, child.name)
+			}
+		}
+	}
+
+	for _, name := range names {
+		ym3.mergeNode(node, findChild(base, name), findChild(ours, name), findChild(theirs, name))
+	}
+}
+
+// mergeNode merges the given versions of a node as a child of the given parent.
+func (ym3 *YamlMerger3) mergeNode(parent *YamlNode, base *YamlNode, ours *YamlNode, theirs *YamlNode) {
+	var kept *YamlNode
+
+	switch {
+	case NodesEqual(ours, theirs):
+		kept = ours
+	case NodesEqual(base, ours):
+		kept = theirs
+	case NodesEqual(base, theirs):
+		kept = ours
+	default:
+		ym3.mergeChanged(parent, base, ours, theirs)
+		return
+	}
+
+	if kept != nil {
+		// nil if deleted
+		CopyNode(kept, NewChildNode(parent))
+	}
+}
+
+// mergeChanged merges the given versions of a node changed on both sides
+// as a child of the given parent.
+func (ym3 *YamlMerger3) mergeChanged(parent *YamlNode, base *YamlNode, ours *YamlNode, theirs *YamlNode) {
+	if ours != nil && theirs != nil && ours.ntype == theirs.ntype && ours.tag == theirs.tag &&
+		(base == nil || base.ntype == ours.ntype) {
+		if ours.ntype == NodeTypeChildren {
+			child := NewChildNode(parent)
+			child.name = ours.name
+			child.ntype = NodeTypeChildren
+			child.source = ours.source
+			child.line = ours.line
+			child.tag = ours.tag
+			mergeComments3(child, base, ours, theirs)
+
+			ym3.mergeChildren(child, base, ours, theirs)
+			return
+		}
+
+		if ours.ntype == NodeTypeList && len(ours.children) == 0 && len(theirs.children) == 0 {
+			child := NewChildNode(parent)
+			CopyNode(ours, child)
+			child.values = mergeLists3(base, ours.values, theirs.values)
+			child.itemComments = mergeItemComments(ours.itemComments, theirs.itemComments)
+			mergeComments3(child, base, ours, theirs)
+			return
+		}
+	}
+
+	ym3.addConflict(parent, base, ours, theirs)
+}
+
+// addConflict records a conflict, and adds ours version (or the markers)
+// to the given parent.
+func (ym3 *YamlMerger3) addConflict(parent *YamlNode, base *YamlNode, ours *YamlNode, theirs *YamlNode) {
+	conflict := &YamlConflict{Base: base, Ours: ours, Theirs: theirs}
+
+	if ym3.markers {
+		child := NewChildNode(parent)
+		child.conflict = conflict
+		if ours != nil {
+			child.name = ours.name
+		} else {
+			child.name = theirs.name
+		}
+		conflict.Path = NodePath(child)
+	} else if ours != nil {
+		child := NewChildNode(parent)
+		CopyNode(ours, child)
+		conflict.Path = NodePath(child)
+	} else {
+		conflict.Path = childNodePath(parent, theirs.name)
+	}
+
+	ym3.conflicts = append(ym3.conflicts, conflict)
+}
+
+// mergeComments3 sets the comments of the given merged node: ours, or theirs
+// if only theirs changed them.
+func mergeComments3(node *YamlNode, base *YamlNode, ours *YamlNode, theirs *YamlNode) {
+	node.comments = ours.comments
+	node.lineComment = ours.lineComment
+
+	if base == nil {
+		return
+	}
+
+	if sameStrings(base.comments, ours.comments) {
+		node.comments = theirs.comments
+	}
+
+	if base.lineComment == ours.lineComment {
+		node.lineComment = theirs.lineComment
+	}
+}
+
+// mergeLists3 returns ours list with the items added by theirs, without the
+// items removed by theirs.
+func mergeLists3(base *YamlNode, ours []string, theirs []string) []string {
+	var baseValues []string
+	if base != nil {
+		baseValues = base.values
+	}
+
+	var merged []string
+
+	for _, item := range ours {
+		if containsValue(baseValues, item) && !containsValue(theirs, item) {
+			// Removed by theirs
+			continue
+		}
+
+		merged = append(merged, item)
+	}
+
+	for _, item := range theirs {
+		if !containsValue(baseValues, item) && !containsValue(merged, item) {
+			// Added by theirs
+			merged = append(merged, item)
+		}
+	}
+
+	return merged
+}
+
+// mergeItemComments returns ours list values comments, with theirs for the
+// values ours has none for.
+func mergeItemComments(ours map[string]*valueComments, theirs map[string]*valueComments) map[string]*valueComments {
+	if len(theirs) == 0 {
+		return ours
+	}
+
+	merged := make(map[string]*valueComments)
+	for value, comments := range theirs {
+		merged[value] = comments
+	}
+	for value, comments := range ours {
+		merged[value] = comments
+	}
+
+	return merged
+}
+
+// containsValue tells if the given list contains the given value.
+func containsValue(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// findChild returns the child of the given (possibly nil) node matching the given name.
+func findChild(node *YamlNode, name string) *YamlNode {
+	if node == nil {
+		return nil
+	}
+
+	return TraverseFindChild(node, name)
+}
+
+// NodesEqual tells if the given nodes (possibly nil) have the same content,
+// comments included.
+// The order of mapping children is not significant, the order of list items is.
+func NodesEqual(node *YamlNode, node2 *YamlNode) bool {
+	if node == nil || node2 == nil {
+		return node == node2
+	}
+
+	if node.name != node2.name || !sameNodeHeaders(node, node2) || len(node.children) != len(node2.children) {
+		return false
+	}
+
+	for i, child := range node.children {
+		child2 := node2.children[i]
+		if node.ntype != NodeTypeList {
+			child2 = TraverseFindChild(node2, child.name)
+		}
+
+		if !NodesEqual(child, child2) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,140 @@
+package simpleyaml
+
+import (
+	"testing"
+)
+
+func TestMergeStream3(t *testing.T) {
+	tests := []struct {
+		name      string
+		base      string
+		ours      string
+		theirs    string
+		markers   bool
+		output    string
+		conflicts []string // Paths, prefixed with the document index
+	}{
+		{
+			name:   "changes of both sides",
+			base:   "a: 1\nb: 1\n",
+			ours:   "a: 2\nb: 1\n",
+			theirs: "a: 1\nb: 1\nc: 1\n",
+			output: "a: 2\nb: 1\nc: 1\n",
+		},
+		{
+			name:   "deletion of one side",
+			base:   "a: 1\nb: 1\n",
+			ours:   "a: 1\n",
+			theirs: "a: 3\nb: 1\n",
+			output: "a: 3\n",
+		},
+		{
+			name:   "list items added and removed",
+			base:   "l:\n  - x\n  - y\n",
+			ours:   "l:\n  - x\n  - y\n  - z\n",
+			theirs: "l:\n  - y\n",
+			output: "l:\n  - y\n  - z\n",
+		},
+		{
+			name:      "conflict keeps ours",
+			base:      "a:\n  b: 1\n  c: 1\n",
+			ours:      "a:\n  b: 2\n  c: 1\n",
+			theirs:    "a:\n  b: 3\n  c: 2\n",
+			output:    "a:\n  b: 2\n  c: 2\n",
+			conflicts: []string{"0:a.b"},
+		},
+		{
+			name:      "conflict with markers",
+			base:      "a: 1\n",
+			ours:      "a: 2\n",
+			theirs:    "a: 3\n",
+			markers:   true,
+			output:    "<<<<<<< ours\na: 2\n=======\na: 3\n>>>>>>> theirs\n",
+			conflicts: []string{"0:a"},
+		},
+		{
+			name:   "comments kept",
+			base:   "# header\na: 1 # the a\nl:\n  # first\n  - x # web\n",
+			ours:   "# header\na: 2 # the a\nl:\n  # first\n  - x # web\n",
+			theirs: "# header\na: 1 # the a\nl:\n  # first\n  - x # web\n  - y # new\n",
+			output: "# header\na: 2 # the a\nl:\n  # first\n  - x # web\n  - y # new\n",
+		},
+		{
+			name:      "leading comments outside the markers",
+			base:      "# header\na: 1\n",
+			ours:      "# header\na: 2\n",
+			theirs:    "# header\na: 3\n",
+			markers:   true,
+			output:    "# header\n<<<<<<< ours\na: 2\n=======\na: 3\n>>>>>>> theirs\n",
+			conflicts: []string{"0:a"},
+		},
+		{
+			name:      "leading comments changed inside the markers",
+			base:      "a: 1\n",
+			ours:      "# ours\na: 2\n",
+			theirs:    "# theirs\na: 3\n",
+			markers:   true,
+			output:    "<<<<<<< ours\n# ours\na: 2\n=======\n# theirs\na: 3\n>>>>>>> theirs\n",
+			conflicts: []string{"0:a"},
+		},
+		{
+			name:   "comment changed by one side",
+			base:   "a: 1 # old\nb:\n  c: 1\n",
+			ours:   "a: 1 # old\nb:\n  c: 1\n",
+			theirs: "a: 1 # new\nb:\n  # about c\n  c: 1\n",
+			output: "a: 1 # new\nb:\n  # about c\n  c: 1\n",
+		},
+		{
+			name:   "comment and value changed by each side",
+			base:   "# a mapping\nb:\n  c: 1\n",
+			ours:   "# b mapping\nb:\n  c: 1\n",
+			theirs: "# a mapping\nb:\n  c: 2\n",
+			output: "# b mapping\nb:\n  c: 2\n",
+		},
+		{
+			name:      "documents merged by position",
+			base:      "a: 1\n---\nb: 1\n",
+			ours:      "a: 2\n---\nb: 2\n",
+			theirs:    "a: 1\n---\nb: 3\n---\nc: 1\n",
+			output:    "a: 2\n---\nb: 2\n---\nc: 1\n",
+			conflicts: []string{"1:b"},
+		},
+		{
+			name:   "document removed",
+			base:   "a: 1\n---\nb: 1\n",
+			ours:   "a: 2\n",
+			theirs: "a: 1\n---\nb: 1\n",
+			output: "a: 2\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			merged, conflicts := MergeStream3(
+				parseRawYamlStream(t, "base.yml", test.base),
+				parseRawYamlStream(t, "ours.yml", test.ours),
+				parseRawYamlStream(t, "theirs.yml", test.theirs),
+				test.markers)
+
+			var paths []string
+			for _, conflict := range conflicts {
+				paths = append(paths, string(rune('0'+conflict.Document))+":"+conflict.Path)
+			}
+
+			if len(paths) != len(test.conflicts) {
+				t.Fatalf("unexpected conflicts %v, expected %v", paths, test.conflicts)
+			}
+
+			for i, path := range paths {
+				if path != test.conflicts[i] {
+					t.Fatalf("unexpected conflicts %v, expected %v", paths, test.conflicts)
+				}
+			}
+
+			output := writeYamlStream(t, merged...)
+			if output != test.output {
+				t.Fatalf("unexpected output:\n%s\nexpected:\n%s", output, test.output)
+			}
+		})
+	}
+}
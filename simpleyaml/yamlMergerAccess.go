@@ -0,0 +1,170 @@
+package simpleyaml
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Access policy file keys
+const (
+	accessKeyAllow = "allow"
+	accessKeyDeny  = "deny"
+)
+
+// AccessPolicy restricts the paths the overlays matching a file pattern may touch.
+type AccessPolicy struct {
+	FilePattern string   // Overlay files glob. e.g.: security.yml or overlays/*.yml
+	Allow       []string // Path patterns allowed (all if empty). e.g.: services.*.cap_*
+	Deny        []string // Path patterns denied. e.g.: networks
+}
+
+// ParseAccessPolicies returns the access policies of the given policy YAML:
+//
+//	security.yml:
+//	  allow:
+//	    - services.*.cap_*
+//	"app-*.yml":
+//	  deny:
+//	    - networks
+func ParseAccessPolicies(yaml *YamlNode) ([]*AccessPolicy, error) {
+	var policies []*AccessPolicy
+
+	for _, fileNode := range yaml.children {
+		policy := &AccessPolicy{FilePattern: unquote(fileNode.name)}
+
+		for _, child := range fileNode.children {
+			var patterns []string
+			for _, value := range child.values {
+				patterns = append(patterns, unquote(value))
+			}
+
+			switch child.name {
+			case accessKeyAllow:
+				policy.Allow = append(policy.Allow, patterns...)
+			case accessKeyDeny:
+				policy.Deny = append(policy.Deny, patterns...)
+			default:
+				return nil, fmt.Errorf("Policy error at `%s` (%s): unknown key `%s`",
+					NodePath(child), nodeLocation(child), child.name)
+			}
+		}
+
+		policies = append(policies, policy)
+	}
+
+	return policies, nil
+}
+
+// AddAccessPolicy restricts the paths the overlays matching the policy file
+// pattern may touch. Every violation is reported at the end of the merge.
+func (ym *YamlMerger) AddAccessPolicy(policy *AccessPolicy) {
+	ym.accessPolicies = append(ym.accessPolicies, policy)
+}
+
+// allows tells if the policy allows to touch the given path.
+func (policy *AccessPolicy) allows(nodePath string) bool {
+	for _, pattern := range policy.Deny {
+		if matchPathOrParent(pattern, nodePath) {
+			return false
+		}
+	}
+
+	if len(policy.Allow) == 0 {
+		return true
+	}
+
+	for _, pattern := range policy.Allow {
+		if matchPathOrParent(pattern, nodePath) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchesFile tells if the policy applies to the given overlay file
+// (matched as is or by base name).
+func (policy *AccessPolicy) matchesFile(file string) bool {
+	matched, _ := filepath.Match(policy.FilePattern, file)
+	if !matched {
+		matched, _ = filepath.Match(policy.FilePattern, filepath.Base(file))
+	}
+
+	return matched
+}
+
+// checkAccess records a violation for every path touched by the childX node
+// that its overlay file may not touch.
+func (ym *YamlMerger) checkAccess(parent0 *YamlNode, child0 *YamlNode, childX *YamlNode) {
+	if child0 != nil && child0.ntype == NodeTypeChildren && childX.ntype == NodeTypeChildren &&
+		childX.tag == "" && len(childX.children) > 0 {
+		// The children are checked one by one
+		return
+	}
+
+	policies := ym.filePolicies(overlayFile(childX))
+	if len(policies) == 0 {
+		return
+	}
+
+	name := childX.name
+	if target := listDirectiveTarget(name); ym.strategicMerge && target != "" {
+		// Changes the list it applies to
+		name = target
+	}
+
+	ym.checkAccessPaths(policies, childNodePath(parent0, name), childX, child0 == nil)
+}
+
+// overlayFile returns the overlay file of the given node: the file including
+// it, or its source.
+func overlayFile(node *YamlNode) string {
+	if node.origin != "" {
+		return node.origin
+	}
+
+	return node.source
+}
+
+// filePolicies returns the access policies of the given overlay file.
+func (ym *YamlMerger) filePolicies(file string) []*AccessPolicy {
+	var policies []*AccessPolicy
+	for _, policy := range ym.accessPolicies {
+		if policy.matchesFile(file) {
+			policies = append(policies, policy)
+		}
+	}
+
+	return policies
+}
+
+// checkAccessPaths records a violation if the given path may not be touched.
+// The leaves of an added node are checked one by one.
+func (ym *YamlMerger) checkAccessPaths(policies []*AccessPolicy, nodePath string, childX *YamlNode, added bool) {
+	if added && childX.ntype == NodeTypeChildren && len(childX.children) > 0 {
+		for _, child := range childX.children {
+			ym.checkAccessPaths(policies, nodePath+TkPathSeparator+EscapePathName(child.name), child, true)
+		}
+
+		return
+	}
+
+	for _, policy := range policies {
+		if !policy.allows(nodePath) {
+			ym.accessViolations = append(ym.accessViolations,
+				fmt.Sprintf("  `%s` (%s): denied by `%s` policy", nodePath, nodeLocation(childX), policy.FilePattern))
+			return
+		}
+	}
+}
+
+// accessError returns an error listing the access violations found (if any).
+func (ym *YamlMerger) accessError() error {
+	if len(ym.accessViolations) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("Access error: %d path(s) not allowed to the overlays:\n%s",
+		len(ym.accessViolations), strings.Join(ym.accessViolations, "\n"))
+}
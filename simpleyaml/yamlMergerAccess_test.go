@@ -0,0 +1,121 @@
+package simpleyaml
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAccessPolicies(t *testing.T) {
+	policies, err := ParseAccessPolicies(parseYaml(t, "policy.yml", "security.yml:\n"+
+		"  allow:\n"+
+		"    - services.*.cap_*\n"+
+		"\"app-*.yml\":\n"+
+		"  deny:\n"+
+		"    - networks\n"+
+		"    - metadata.annotations.*\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base := "metadata:\n" +
+		"  annotations:\n" +
+		"    example.com/team: core\n" +
+		"networks:\n" +
+		"  web:\n" +
+		"    name: web\n" +
+		"services:\n" +
+		"  php:\n" +
+		"    image: php\n"
+
+	tests := []struct {
+		name       string
+		file       string
+		overlay    string
+		violations []string
+	}{
+		{
+			name:    "allowed paths",
+			file:    "security.yml",
+			overlay: "services:\n  php:\n    cap_drop:\n      - ALL\n",
+		},
+		{
+			name:       "paths out of the allowed ones",
+			file:       "security.yml",
+			overlay:    "services:\n  php:\n    image: x\n    cap_add:\n      - NET_ADMIN\n",
+			violations: []string{"services.php.image"},
+		},
+		{
+			name:       "denied parent",
+			file:       "app-1.yml",
+			overlay:    "networks:\n  web:\n    name: other\n",
+			violations: []string{"networks.web.name"},
+		},
+		{
+			name:       "denied key with a slash",
+			file:       "app-1.yml",
+			overlay:    "metadata:\n  annotations:\n    example.com/team: other\n",
+			violations: []string{"metadata.annotations.example\\.com/team"},
+		},
+		{
+			name:    "file without policy",
+			file:    "other.yml",
+			overlay: "networks: !delete\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ym := newTestMerger([]*YamlNode{parseYaml(t, "input1.yml", base), parseYaml(t, test.file, test.overlay)})
+			for _, policy := range policies {
+				ym.AddAccessPolicy(policy)
+			}
+
+			_, _, err := ym.Merge()
+			checkAccessViolations(t, err, test.violations)
+		})
+	}
+}
+
+func TestAccessPoliciesOfIncludedNodes(t *testing.T) {
+	overlay, err := parseIncludingFile(t, "app-1.yml", map[string]string{
+		"app-1.yml":    "networks: !include frag/net.yml\n",
+		"frag/net.yml": "web:\n  name: other\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ym := newTestMerger([]*YamlNode{parseYaml(t, "input1.yml", "networks:\n  web:\n    name: web\n"), overlay})
+	ym.AddAccessPolicy(&AccessPolicy{FilePattern: "app-*.yml", Deny: []string{"networks"}})
+
+	_, _, err = ym.Merge()
+	checkAccessViolations(t, err, []string{"networks.web.name"})
+}
+
+// checkAccessViolations fails if the given merge error does not report
+// exactly the given denied paths.
+func checkAccessViolations(t *testing.T, err error, paths []string) {
+	t.Helper()
+
+	if len(paths) == 0 {
+		if err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	if err == nil {
+		t.Fatalf("expected access violations at %v", paths)
+	}
+
+	lines := strings.Split(err.Error(), "\n")
+	if len(lines) != len(paths)+1 || !strings.HasPrefix(lines[0], "Access error:") {
+		t.Fatalf("expected access violations at %v, got %v", paths, err)
+	}
+
+	for i, path := range paths {
+		if !strings.HasPrefix(lines[i+1], "  `"+path+"` (") {
+			t.Fatalf("expected a violation at `%s`, got %q", path, lines[i+1])
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package simpleyaml
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SetFinalPath sets the nodes matching the given path pattern as final:
+// overlays may not override nor delete them (or their children).
+// e.g.: services.*.cap_drop
+func (ym *YamlMerger) SetFinalPath(pattern string) {
+	ym.finalPaths = append(ym.finalPaths, pattern)
+}
+
+// isFinal tells if the given node of the merged YAML (or one of its parents)
+// is final.
+func (ym *YamlMerger) isFinal(node *YamlNode) bool {
+	for ; node != nil && node.parent != nil; node = node.parent {
+		if ym.isFinalNode(node) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isFinalNode tells if the given node of the merged YAML is final itself.
+func (ym *YamlMerger) isFinalNode(node *YamlNode) bool {
+	if node.tag == TagFinal {
+		return true
+	}
+
+	path := NodePath(node)
+	for _, pattern := range ym.finalPaths {
+		if MatchPath(pattern, path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkFinal returns an error if the childX node overrides a final node.
+// Returns true if childX restates the final child0 node as is (nothing to merge).
+func (ym *YamlMerger) checkFinal(parent0 *YamlNode, child0 *YamlNode, childX *YamlNode) (bool, error) {
+	if target := listDirectiveTarget(childX.name); ym.strategicMerge && target != "" {
+		return ym.checkFinalListDirective(TraverseFindChild(parent0, target), childX)
+	}
+
+	if child0 == nil {
+		if childX.tag != TagDelete && ym.isFinal(parent0) {
+			return false, fmt.Errorf("Final error at `%s` (%s): cannot add `%s` to a final node",
+				NodePath(parent0), nodeLocation(childX), childX.name)
+		}
+
+		return false, nil
+	}
+
+	if childX.tag == TagDefault || childX.tag == TagRequired {
+		// Nothing overridden
+		return false, nil
+	}
+
+	patch := ""
+	if ym.strategicMerge && childX.ntype == NodeTypeChildren {
+		patch = nodePatch(childX)
+	}
+
+	deleted := childX.tag == TagDelete || patch == patchDelete ||
+		(childX.ntype == NodeTypeScalar && childX.values[0] == ym.delTk)
+
+	if !ym.isFinal(child0) {
+		return false, ym.checkFinalDescendants(child0, childX, deleted, patch)
+	}
+
+	if deleted {
+		return false, fmt.Errorf("Final error at `%s` (%s): a final node cannot be deleted",
+			NodePath(child0), nodeLocation(childX))
+	}
+
+	if child0.ntype == NodeTypeChildren && childX.ntype == NodeTypeChildren && !isDirectiveTag(childX.tag) &&
+		patch == "" {
+		// The children are checked one by one
+		return false, nil
+	}
+
+	if isOperatorTag(childX.tag) {
+		// Restated only if the result is the final value. e.g.: !max 2 on 2
+		result := new(YamlNode)
+		CopyNode(child0, result)
+
+		if mergeOperator(result, childX) != nil || !sameContent(child0, result) {
+			return false, fmt.Errorf("Final error at `%s` (%s): a final node cannot be overridden",
+				NodePath(child0), nodeLocation(childX))
+		}
+
+		return true, nil
+	}
+
+	if !sameContent(child0, childX) {
+		return false, fmt.Errorf("Final error at `%s` (%s): a final node cannot be overridden",
+			NodePath(child0), nodeLocation(childX))
+	}
+
+	return true, nil
+}
+
+// checkFinalDescendants returns an error if the childX node deletes or replaces
+// the given child0 node, not final, with final descendants. A replacing node
+// must restate them as is (their !final tag included).
+func (ym *YamlMerger) checkFinalDescendants(child0 *YamlNode, childX *YamlNode, deleted bool, patch string) error {
+	replaced := childX.tag == TagReplace || patch == patchReplace
+
+	if child0.ntype != childX.ntype && !deleted && !replaced {
+		// Type override, unless the base is kept or both forms are accepted
+		path := NodePath(child0)
+		_, isNormalized := ym.normalizedDelimFor(path)
+
+		replaced = ym.conflictPolicyFor(path) != PolicyBaseWins &&
+			!(isNormalized && isNormalizable(child0) && isNormalizable(childX))
+	}
+
+	if !deleted && !replaced {
+		// The children are checked one by one
+		return nil
+	}
+
+	replacing := childX
+	if deleted {
+		replacing = nil
+	}
+
+	return ym.checkFinalChildren(child0, replacing, childX, deleted)
+}
+
+// checkFinalChildren returns an error if a final descendant of the given node
+// is deleted by the given overlay node, or not restated as is by the given
+// replacing node (nil if none).
+func (ym *YamlMerger) checkFinalChildren(node *YamlNode, nodeX *YamlNode, overlay *YamlNode, deleted bool) error {
+	for i, child := range node.children {
+		var childX *YamlNode
+		if nodeX != nil && nodeX.ntype == node.ntype {
+			if !isListItem(child) {
+				childX = TraverseFindChild(nodeX, child.name)
+			} else if i < len(nodeX.children) {
+				childX = nodeX.children[i]
+			}
+		}
+
+		if !ym.isFinalNode(child) {
+			err := ym.checkFinalChildren(child, childX, overlay, deleted)
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if deleted {
+			return fmt.Errorf("Final error at `%s` (%s): a final node cannot be deleted",
+				NodePath(child), nodeLocation(overlay))
+		}
+
+		if childX == nil || !sameContent(child, childX) || (child.tag == TagFinal && childX.tag != TagFinal) {
+			return fmt.Errorf("Final error at `%s` (%s): a final node cannot be overridden",
+				NodePath(child), nodeLocation(overlay))
+		}
+	}
+
+	return nil
+}
+
+// checkFinalListDirective returns an error if the given $setElementOrder or
+// $deleteFromPrimitiveList directive changes the given final list.
+// Returns true if it leaves the list as is.
+func (ym *YamlMerger) checkFinalListDirective(list *YamlNode, childX *YamlNode) (bool, error) {
+	if list == nil || list.ntype != NodeTypeList || !ym.isFinal(list) {
+		return false, nil
+	}
+
+	result := new(YamlNode)
+	CopyNode(list, result)
+
+	if strings.HasPrefix(childX.name, tkSetElementOrder) {
+		ym.orderList(result, childX)
+	} else {
+		deleteFromList(result, childX)
+	}
+
+	if !sameContent(list, result) {
+		return false, fmt.Errorf("Final error at `%s` (%s): a final node cannot be overridden",
+			NodePath(list), nodeLocation(childX))
+	}
+
+	return true, nil
+}
+
+// sameContent tells if the given nodes have the same values and children,
+// whatever their tags.
+func sameContent(node *YamlNode, node2 *YamlNode) bool {
+	if node.ntype != node2.ntype || len(node.values) != len(node2.values) ||
+		len(node.children) != len(node2.children) {
+		return false
+	}
+
+	for i, value := range node.values {
+		if node2.values[i] != value {
+			return false
+		}
+	}
+
+	for i, child := range node.children {
+		child2 := node2.children[i]
+		if node.ntype != NodeTypeList {
+			child2 = TraverseFindChild(node2, child.name)
+		}
+
+		if child2 == nil || !sameContent(child, child2) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,100 @@
+package simpleyaml
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFinalDescendants(t *testing.T) {
+	base := "networks:\n" +
+		"  web: !final\n" +
+		"    name: web\n" +
+		"services:\n" +
+		"  php:\n" +
+		"    image: php\n" +
+		"    cap_drop: !final\n" +
+		"      - ALL\n"
+
+	tests := []struct {
+		name       string
+		overlay    string
+		finalPaths []string
+		policy     uint
+		err        string
+	}{
+		{
+			name:    "parent deleted by tag",
+			overlay: "networks: !delete\n",
+			err:     "Final error at `networks.web` (",
+		},
+		{
+			name:    "parent deleted by token",
+			overlay: "services:\n  php: nil\n",
+			err:     "Final error at `services.php.cap_drop` (",
+		},
+		{
+			name:    "parent replaced",
+			overlay: "services:\n  php: !replace\n    image: x\n",
+			err:     "input2.yml:2): a final node cannot be overridden",
+		},
+		{
+			name:    "parent type overridden",
+			overlay: "services:\n  php: x\n",
+			policy:  PolicyOverlayWins,
+			err:     "Final error at `services.php.cap_drop` (",
+		},
+		{
+			name:       "parent of a final path deleted",
+			overlay:    "networks:\n  web:\n    name: web\nservices: !delete\n",
+			finalPaths: []string{"services.*.image"},
+			err:        "Final error at `services.php.image` (",
+		},
+		{
+			name:    "parent replaced restating the final nodes",
+			overlay: "services:\n  php: !replace\n    image: x\n    cap_drop: !final\n      - ALL\n",
+		},
+		{
+			name:    "parent replaced restating the final nodes without their tag",
+			overlay: "services:\n  php: !replace\n    image: x\n    cap_drop:\n      - ALL\n",
+			err:     "Final error at `services.php.cap_drop` (",
+		},
+		{
+			name:    "parent type kept by the base",
+			overlay: "services:\n  php: x\n",
+			policy:  PolicyBaseWins,
+		},
+		{
+			name:    "sibling deleted",
+			overlay: "services:\n  php:\n    image: nil\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ym := newTestMerger([]*YamlNode{parseYaml(t, "input1.yml", base), parseYaml(t, "input2.yml", test.overlay)})
+			if test.policy != PolicyError {
+				ym.SetConflictPolicy(test.policy)
+			}
+
+			for _, pattern := range test.finalPaths {
+				ym.SetFinalPath(pattern)
+			}
+
+			merged, _, err := ym.Merge()
+			if test.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !strings.Contains(writeYamlStream(t, merged), "    cap_drop:\n      - ALL\n") {
+					t.Fatalf("expected the final node kept, got:\n%s", writeYamlStream(t, merged))
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("expected error containing %q, got %v", test.err, err)
+			}
+		})
+	}
+}
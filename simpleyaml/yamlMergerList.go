@@ -11,14 +11,50 @@ const (
 	tkRawDelimPerListPostValue = ','
 )
 
+// List merge modes
+const (
+	ListModeAppend      uint = iota // Append the new items, keep the existing ones
+	ListModeReplace                 // Replace the whole list
+	ListModeMountTarget             // Merge by mount target. e.g.: ./src:/mnt/app:ro => /mnt/app
+	ListModePrepend                 // Prepend the new items, keep the existing ones
+)
+
+// listModeTags is the list merge mode of the directive tags.
+var listModeTags = map[string]uint{
+	TagAppend:  ListModeAppend,
+	TagPrepend: ListModePrepend,
+	TagReplace: ListModeReplace,
+}
+
 // mergeNodesList merges the lists of the given two nodes.
 func (ym *YamlMerger) mergeNodesList(node *YamlNode, node2 *YamlNode) error {
 	var mlistMerged map[string]string
 	var err error
 
+	nptr := nodePointerToInt(node)
+
+	mode, hasMode := listModeTags[node2.tag]
+	if !hasMode {
+		mode, hasMode = ym.listModes[node2.name]
+	}
+
+	if !hasMode && ym.strategicMerge && !hasPrimitiveListDirective(node2) {
+		// Primitive lists are replaced by the strategic merge patch
+		mode, hasMode = ListModeReplace, true
+	}
+
+	if hasMode {
+		node.values = mergeListsByMode(node.values, node2.values, mode, ym.delTk)
+		node.source = node2.source
+		node.line = node2.line
+
+		delete(ym.mappedLists, nptr)
+
+		return nil
+	}
+
 	delim, isDelimited := ym.dplMap[node2.name]
 
-	nptr := nodePointerToInt(node)
 	mlist, _ := ym.mappedLists[nptr]
 
 	if isDelimited {
@@ -27,7 +63,9 @@ func (ym *YamlMerger) mergeNodesList(node *YamlNode, node2 *YamlNode) error {
 		mlistMerged = mergeNodesListBasic(node, node2, &mlist, ym.delTk)
 	}
 
-	node.values = mappedListToList(mlistMerged, delim)
+	node.values = mappedListToOrderedList(mlistMerged, delim, node.values, node2.values)
+	node.source = node2.source
+	node.line = node2.line
 
 	ym.mappedLists[nptr] = mlistMerged
 
@@ -171,18 +209,102 @@ func listToMappedList(list []string) map[string]string {
 	return mp
 }
 
-// mappedListToList returns a list from the given mapped list.
+// mappedListToOrderedList returns a list from the given mapped list, ordered
+// as the items first appear in the given lists.
 // delim Delimiter to use; if basic list set it to empty
-func mappedListToList(mlist map[string]string, delim string) []string {
+func mappedListToOrderedList(mlist map[string]string, delim string, lists ...[]string) []string {
 	list := []string{}
+	added := make(map[string]bool)
+
+	for _, l := range lists {
+		for _, item := range l {
+			k := item
+			if delim != "" {
+				k = strings.SplitN(item, delim, 2)[0]
+			}
 
-	for k, v := range mlist {
-		list = append(list, k+delim+v)
+			v, found := mlist[k]
+			if !found || added[k] {
+				continue
+			}
+
+			list = append(list, k+delim+v)
+			added[k] = true
+		}
 	}
 
 	return list
 }
 
+// mergeListsByMode returns the merge of the given two lists for the given mode.
+// delTk Deletion token. If the value ends with a colon followed by your token
+//                       then the matching item will be deleted.
+func mergeListsByMode(list []string, list2 []string, mode uint, delTk string) []string {
+	if mode == ListModeReplace {
+		return list2
+	}
+
+	keyOf := func(item string) string {
+		return item
+	}
+
+	if mode == ListModeMountTarget {
+		keyOf = mountTarget
+	}
+
+	merged := make([]string, len(list))
+	copy(merged, list)
+	prepended := 0
+
+	for _, item2 := range list2 {
+		c := len(item2)
+		tkLen := len(delTk)
+		isDeletion := delTk != "" && c > tkLen+1 && item2[c-(tkLen+1):] == ":"+delTk
+		if isDeletion {
+			item2 = item2[0 : c-(tkLen+1)]
+		}
+
+		k2 := keyOf(item2)
+		index := -1
+
+		for i, item := range merged {
+			if keyOf(item) == k2 {
+				index = i
+				break
+			}
+		}
+
+		if isDeletion {
+			if index >= 0 {
+				merged = append(merged[:index], merged[index+1:]...)
+			}
+			if index >= 0 && index < prepended {
+				prepended--
+			}
+		} else if index >= 0 {
+			merged[index] = item2
+		} else if mode == ListModePrepend {
+			merged = append(merged[:prepended], append([]string{item2}, merged[prepended:]...)...)
+			prepended++
+		} else {
+			merged = append(merged, item2)
+		}
+	}
+
+	return merged
+}
+
+// mountTarget returns the target of the given mount.
+// e.g.: ./src:/mnt/app:ro => /mnt/app
+func mountTarget(mount string) string {
+	split := strings.Split(unquote(mount), ":")
+	if len(split) == 1 {
+		return split[0]
+	}
+
+	return split[1]
+}
+
 // RawDelimPerListToMap returns the map of the given raw "Delimiter Per List" format.
 // Raw format: listName1:delim1,listName2:delim2[,...]
 func RawDelimPerListToMap(str string) map[string]string {
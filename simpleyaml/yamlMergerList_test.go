@@ -0,0 +1,71 @@
+package simpleyaml
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMergeListModeTags(t *testing.T) {
+	base := "networks:\n  - web\n  - db\n"
+
+	tests := []struct {
+		name     string
+		overlay  string
+		expected []string // nil if the node is removed
+	}{
+		{name: "untagged", overlay: "networks:\n  - db\n  - cache\n",
+			expected: []string{"web", "db", "cache"}},
+		{name: "append", overlay: "networks: !append\n  - db\n  - cache\n",
+			expected: []string{"web", "db", "cache"}},
+		{name: "append deletion", overlay: "networks: !append\n  - web:nil\n",
+			expected: []string{"db"}},
+		{name: "prepend", overlay: "networks: !prepend\n  - cache\n",
+			expected: []string{"cache", "web", "db"}},
+		{name: "replace", overlay: "networks: !replace\n  - cache\n",
+			expected: []string{"cache"}},
+		{name: "default", overlay: "networks: !default\n  - cache\n",
+			expected: []string{"web", "db"}},
+		{name: "delete", overlay: "networks: !delete\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			yamls := []*YamlNode{parseYaml(t, "input1.yml", base), parseYaml(t, "input2.yml", test.overlay)}
+
+			merged, _, err := newTestMerger(yamls).Merge()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			networks := TraverseFindChild(merged, "networks")
+			if test.expected == nil {
+				if networks != nil {
+					t.Fatalf("expected networks removed, got %v", networks.values)
+				}
+				return
+			}
+
+			if networks == nil || networks.tag != "" ||
+				strings.Join(networks.values, " ") != strings.Join(test.expected, " ") {
+				t.Fatalf("expected %v, got %+v", test.expected, networks)
+			}
+		})
+	}
+}
+
+func TestMergeListModeTagsOnAbsentNode(t *testing.T) {
+	yamls := []*YamlNode{
+		parseYaml(t, "input1.yml", "image: php\n"),
+		parseYaml(t, "input2.yml", "networks: !prepend\n  - \"80\"\nvolumes: !delete\n"),
+	}
+
+	merged, _, err := newTestMerger(yamls).Merge()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "image: php\nnetworks:\n  - \"80\"\n"
+	if output := writeYamlStream(t, merged); output != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, output)
+	}
+}
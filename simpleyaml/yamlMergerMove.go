@@ -0,0 +1,217 @@
+package simpleyaml
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Argument keys of the move directives on mappings
+// e.g.: php-worker: !copyFrom {$from: services.php, command: php worker.php}
+const (
+	TkDirectiveFrom = "$from" // !copyFrom
+	TkDirectiveTo   = "$to"   // !rename and !moveTo
+)
+
+// isMoveTag tells if the given tag renames, copies or moves a node.
+func isMoveTag(tag string) bool {
+	return tag == TagRename || tag == TagCopyFrom || tag == TagMoveTo
+}
+
+// moveArgument returns the argument of the given move directive node: its
+// scalar value, or the value of its argument key (removed from the node).
+func moveArgument(node *YamlNode) string {
+	if node.ntype == NodeTypeScalar {
+		if len(node.values) == 0 {
+			return ""
+		}
+
+		return unquote(node.values[0])
+	}
+
+	key := TkDirectiveTo
+	if node.tag == TagCopyFrom {
+		key = TkDirectiveFrom
+	}
+
+	argNode := TraverseFindChild(node, key)
+	if argNode == nil || argNode.ntype != NodeTypeScalar || len(argNode.values) == 0 {
+		return ""
+	}
+
+	RemoveChildNode(argNode)
+
+	return unquote(argNode.values[0])
+}
+
+// mergeMoveDirective applies the rename, copy or move directive of the childX
+// node against the merged YAML, then merges the children of childX into the
+// resulting node. e.g.: php-worker: !copyFrom services.php
+func (ym *YamlMerger) mergeMoveDirective(parent0 *YamlNode, child0 *YamlNode, childX *YamlNode) error {
+	// Detached copy: the merge must not continue on childX siblings
+	nodeX := new(YamlNode)
+	CopyNode(childX, nodeX)
+
+	arg := moveArgument(nodeX)
+
+	if arg == "" {
+		return fmt.Errorf("Directive error at `%s` (%s): missing argument of %s",
+			childNodePath(parent0, childX.name), nodeLocation(childX), childX.tag)
+	}
+
+	if child0 == nil && childX.tag != TagCopyFrom {
+		return fmt.Errorf("Directive error at `%s` (%s): node to %s not found",
+			childNodePath(parent0, childX.name), nodeLocation(childX), strings.TrimPrefix(childX.tag, TkTag))
+	}
+
+	var target *YamlNode
+	var err error
+
+	switch childX.tag {
+	case TagRename:
+		err = ym.checkDestination(childX, childNodePath(parent0, arg))
+		if err == nil {
+			target, err = ym.renameNode(parent0, child0, childX, arg)
+		}
+	case TagCopyFrom:
+		target, err = ym.copyNodeFrom(parent0, child0, childX, arg)
+	case TagMoveTo:
+		err = ym.checkDestination(childX, arg)
+		if err == nil {
+			target, err = ym.moveNodeTo(child0, childX, arg)
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	firstX := TraverseDown(nodeX)
+	if firstX != nil {
+		if target.ntype != NodeTypeChildren {
+			return fmt.Errorf("Directive error at `%s` (%s): cannot merge children into `%s`, not a mapping",
+				childNodePath(parent0, childX.name), nodeLocation(childX), NodePath(target))
+		}
+
+		err = ym.mergeNodes(target, firstX)
+		if err != nil {
+			return err
+		}
+	}
+
+	return ym.mergeNextNode(parent0, childX)
+}
+
+// checkDestination returns an error if the node renamed or moved by the
+// childX directive would be added to a final node, and records the access
+// violation if its overlay file may not touch the destination path.
+func (ym *YamlMerger) checkDestination(childX *YamlNode, path string) error {
+	names := SplitPath(path)
+
+	// Closest existing parent of the destination
+	parent := ym.finalYaml
+	for _, name := range names[:len(names)-1] {
+		child := TraverseFindChild(parent, name)
+		if child == nil {
+			break
+		}
+
+		parent = child
+	}
+
+	if ym.isFinal(parent) {
+		return fmt.Errorf("Final error at `%s` (%s): cannot add `%s` to a final node",
+			NodePath(parent), nodeLocation(childX), path)
+	}
+
+	policies := ym.filePolicies(overlayFile(childX))
+	if len(policies) > 0 {
+		ym.checkAccessPaths(policies, path, childX, false)
+	}
+
+	return nil
+}
+
+// renameNode renames the child0 node. e.g.: !rename newname
+func (ym *YamlMerger) renameNode(parent0 *YamlNode, child0 *YamlNode, childX *YamlNode, name string) (*YamlNode, error) {
+	if TraverseFindChild(parent0, name) != nil {
+		return nil, fmt.Errorf("Directive error at `%s` (%s): cannot rename to `%s`, already exists",
+			NodePath(child0), nodeLocation(childX), name)
+	}
+
+	child0.name = name
+
+	return child0, nil
+}
+
+// copyNodeFrom sets the child0 node (created if missing) as a copy of the node
+// at the given path of the merged YAML. e.g.: !copyFrom services.php
+func (ym *YamlMerger) copyNodeFrom(parent0 *YamlNode, child0 *YamlNode, childX *YamlNode, path string) (*YamlNode, error) {
+	source := TraverseFindPath(ym.finalYaml, path)
+	if source == nil {
+		return nil, fmt.Errorf("Directive error at `%s` (%s): cannot copy `%s`, not found",
+			childNodePath(parent0, childX.name), nodeLocation(childX), path)
+	}
+
+	if child0 == nil {
+		child0 = NewChildNode(parent0)
+	} else {
+		delete(ym.mappedLists, nodePointerToInt(child0))
+	}
+
+	if source == child0 {
+		return child0, nil
+	}
+
+	// Detached copy: the source may be an ancestor of child0
+	copied := new(YamlNode)
+	CopyNode(source, copied)
+
+	ReplaceNode(child0, copied)
+	child0.name = childX.name
+
+	return child0, nil
+}
+
+// moveNodeTo moves the child0 node to the given path of the merged YAML.
+// The missing parents are created. e.g.: !moveTo services.app
+func (ym *YamlMerger) moveNodeTo(child0 *YamlNode, childX *YamlNode, path string) (*YamlNode, error) {
+	sourcePath := NodePath(child0)
+	if path == sourcePath || strings.HasPrefix(path, sourcePath+TkPathSeparator) {
+		return nil, fmt.Errorf("Directive error at `%s` (%s): cannot move a node into itself",
+			sourcePath, nodeLocation(childX))
+	}
+
+	if TraverseFindPath(ym.finalYaml, path) != nil {
+		return nil, fmt.Errorf("Directive error at `%s` (%s): cannot move to `%s`, already exists",
+			sourcePath, nodeLocation(childX), path)
+	}
+
+	names := SplitPath(path)
+	parent := ym.finalYaml
+
+	for _, name := range names[:len(names)-1] {
+		child := TraverseFindChild(parent, name)
+		if child == nil {
+			child = NewChildNode(parent)
+			child.name = name
+			child.ntype = NodeTypeChildren
+			child.source = childX.source
+			child.line = childX.line
+		}
+
+		if child.ntype != NodeTypeChildren {
+			return nil, fmt.Errorf("Directive error at `%s` (%s): cannot move to `%s`, `%s` is not a mapping",
+				sourcePath, nodeLocation(childX), path, NodePath(child))
+		}
+
+		parent = child
+	}
+
+	RemoveChildNode(child0)
+
+	moved := NewChildNode(parent)
+	CopyNode(child0, moved)
+	moved.name = names[len(names)-1]
+
+	return moved, nil
+}
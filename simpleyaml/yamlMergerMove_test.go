@@ -0,0 +1,65 @@
+package simpleyaml
+
+import (
+	"testing"
+)
+
+func TestMergeMoveDirectives(t *testing.T) {
+	base := "services:\n  php:\n    image: php\n    command: php-fpm\n"
+
+	runMergeTests(t, []mergeTest{
+		{
+			name:   "node renamed",
+			inputs: []string{base, "services:\n  php: !rename app\n"},
+			output: "services:\n  app:\n    image: php\n    command: php-fpm\n",
+		},
+		{
+			name:   "node copied",
+			inputs: []string{base, "services:\n  worker: !copyFrom services.php\n"},
+			output: "services:\n  php:\n    image: php\n    command: php-fpm\n  worker:\n    image: php\n    command: php-fpm\n",
+		},
+		{
+			name:   "node copied with children",
+			inputs: []string{base, "services:\n  worker: !copyFrom\n    $from: services.php\n    command: php worker.php\n"},
+			output: "services:\n  php:\n    image: php\n    command: php-fpm\n  worker:\n    image: php\n    command: php worker.php\n",
+		},
+		{
+			name:   "node moved",
+			inputs: []string{base, "services:\n  php: !moveTo archive.php\n"},
+			output: "services:\narchive:\n  php:\n    image: php\n    command: php-fpm\n",
+		},
+		{
+			name:   "missing argument",
+			inputs: []string{base, "services:\n  php: !moveTo\n    image: php:8\n"},
+			err:    "missing argument of !moveTo",
+		},
+		{
+			name:   "move to a final node",
+			inputs: []string{base + "networks: !final\n  default: {}\n", "services:\n  php: !moveTo networks.hacked\n"},
+			err:    "cannot add `networks.hacked` to a final node",
+		},
+		{
+			name:   "rename in a final node",
+			inputs: []string{"services: !final\n  php:\n    image: php\n", "services:\n  php: !rename app\n"},
+			err:    "Final error",
+		},
+	}, newTestMerger)
+
+	runMergeTests(t, []mergeTest{
+		{
+			name:   "move to a denied path",
+			inputs: []string{base, "services:\n  php: !moveTo networks.hacked\n"},
+			err:    "`networks.hacked`",
+		},
+		{
+			name:   "rename to a denied path",
+			inputs: []string{base, "services:\n  php: !rename app\n"},
+			err:    "`services.app`",
+		},
+	}, func(yamls []*YamlNode) *YamlMerger {
+		merger := newTestMerger(yamls)
+		merger.AddAccessPolicy(&AccessPolicy{FilePattern: "input2.yml", Deny: []string{"networks", "services.app"}})
+
+		return merger
+	})
+}
@@ -0,0 +1,128 @@
+package simpleyaml
+
+import (
+	"fmt"
+	"strings"
+)
+
+// normalizedPath is a path pattern whose list and mapping forms are equivalent.
+// e.g.: services.*.environment with "=" (KEY=VAL list <=> KEY: VAL mapping)
+type normalizedPath struct {
+	pattern string
+	delim   string
+}
+
+// SetNormalizedPath sets the paths matching the given pattern as accepting
+// either a delimited list or a mapping. e.g.: services.*.environment with "="
+// Those nodes are merged by key and written in the base form.
+func (ym *YamlMerger) SetNormalizedPath(pattern string, delim string) {
+	ym.normalizedPaths = append(ym.normalizedPaths, normalizedPath{pattern, delim})
+}
+
+// normalizedDelimFor returns the delimiter of the given normalized path.
+func (ym *YamlMerger) normalizedDelimFor(path string) (string, bool) {
+	for _, np := range ym.normalizedPaths {
+		if MatchPath(np.pattern, path) {
+			return np.delim, true
+		}
+	}
+
+	return "", false
+}
+
+// isNormalizable tells if the given node can be converted between
+// list and mapping forms.
+func isNormalizable(node *YamlNode) bool {
+	return node.ntype == NodeTypeList || node.ntype == NodeTypeChildren
+}
+
+// mergeNormalized merges the childX node into the child0 node as mappings,
+// then restores the child0 form.
+func (ym *YamlMerger) mergeNormalized(
+	parent0 *YamlNode,
+	child0 *YamlNode,
+	childX *YamlNode,
+	delim string,
+) error {
+	baseIsList := child0.ntype == NodeTypeList
+
+	if baseIsList {
+		delete(ym.mappedLists, nodePointerToInt(child0))
+		listToMapping(child0, delim)
+	}
+
+	// Detached copy: the merge must not continue on childX siblings
+	nodeX := new(YamlNode)
+	CopyNode(childX, nodeX)
+
+	if nodeX.ntype == NodeTypeList {
+		listToMapping(nodeX, delim)
+	}
+
+	firstX := TraverseDown(nodeX)
+	if firstX != nil {
+		err := ym.mergeNodes(child0, firstX)
+		if err != nil {
+			return err
+		}
+	}
+
+	if baseIsList {
+		err := mappingToList(child0, delim)
+		if err != nil {
+			return err
+		}
+	}
+
+	return ym.mergeNextNode(parent0, childX)
+}
+
+// listToMapping converts the given delimited list node into a mapping node.
+// e.g.: - KEY=VAL => KEY: VAL
+func listToMapping(node *YamlNode, delim string) {
+	values := node.values
+
+	node.ntype = NodeTypeChildren
+	node.values = nil
+	node.children = nil
+
+	for _, item := range values {
+		split := strings.SplitN(unquote(item), delim, 2)
+
+		child := NewChildNode(node)
+		child.name = split[0]
+		child.source = node.source
+		child.line = node.line
+		child.ntype = NodeTypeChildren
+
+		if len(split) > 1 && split[1] != "" {
+			child.ntype = NodeTypeScalar
+			child.values = []string{split[1]}
+		}
+	}
+}
+
+// mappingToList converts the given mapping node into a delimited list node.
+// e.g.: KEY: VAL => - KEY=VAL
+func mappingToList(node *YamlNode, delim string) error {
+	var values []string
+
+	for _, child := range node.children {
+		if child.ntype == NodeTypeScalar {
+			// Quoted if needed. e.g.: "B=a: b"
+			values = append(values, quoteValue(child.name+delim+unquote(child.values[0]), child.values[0]))
+		} else if len(child.children) == 0 && child.ntype == NodeTypeChildren {
+			// No value
+			values = append(values, child.name)
+		} else {
+			return fmt.Errorf("Fatal error: `%s` can't be converted to a list item (%s)",
+				NodePath(child), nodeLocation(child))
+		}
+	}
+
+	node.ntype = NodeTypeList
+	node.values = values
+	node.children = nil
+
+	return nil
+}
@@ -0,0 +1,158 @@
+package simpleyaml
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// nodeTypeNames is the name of the node types (for display).
+var nodeTypeNames = map[uint]string{
+	NodeTypeChildren: "mapping",
+	NodeTypeScalar:   "scalar",
+	NodeTypeList:     "list",
+}
+
+// isOperatorTag tells if the given tag combines the overlay scalar with the
+// merged one.
+func isOperatorTag(tag string) bool {
+	return tag == TagAdd || tag == TagMax || tag == TagMin || tag == TagConcat
+}
+
+// mergeOperator combines the childX scalar with the child0 scalar according to
+// the operator tag of childX. e.g.: replicas: !add 2
+func mergeOperator(child0 *YamlNode, childX *YamlNode) error {
+	if child0.ntype != NodeTypeScalar || childX.ntype != NodeTypeScalar {
+		nodeType := child0.ntype
+		if child0.ntype == NodeTypeScalar {
+			nodeType = childX.ntype
+		}
+
+		return fmt.Errorf("Operator error at `%s` (%s): %s only applies to scalars, found a %s",
+			NodePath(child0), nodeLocation(childX), childX.tag, nodeTypeNames[nodeType])
+	}
+
+	value0, valueX := unquote(child0.values[0]), unquote(childX.values[0])
+
+	var value string
+
+	if childX.tag == TagConcat {
+		value = quoteValue(value0+valueX, child0.values[0])
+	} else {
+		number0, isNumber0 := parseNumber(value0)
+		numberX, isNumberX := parseNumber(valueX)
+
+		if !isNumber0 || !isNumberX {
+			notNumber := value0
+			if isNumber0 {
+				notNumber = valueX
+			}
+
+			return fmt.Errorf("Operator error at `%s` (%s): %s only applies to numbers, found `%s`",
+				NodePath(child0), nodeLocation(childX), childX.tag, notNumber)
+		}
+
+		integer0, isInteger0 := parseInteger(value0)
+		integerX, isIntegerX := parseInteger(valueX)
+
+		if isInteger0 && isIntegerX {
+			// No float rounding. e.g.: 9007199254740993
+			result, isValid := operateIntegers(childX.tag, integer0, integerX)
+			if !isValid {
+				return fmt.Errorf("Operator error at `%s` (%s): %s overflows the integers",
+					NodePath(child0), nodeLocation(childX), childX.tag)
+			}
+
+			value = strconv.FormatInt(result, 10)
+		} else {
+			value = formatNumber(operateFloats(childX.tag, number0, numberX), strings.ContainsAny(value0+valueX, ".eE"))
+		}
+	}
+
+	child0.values = []string{value}
+	child0.source = childX.source
+	child0.line = childX.line
+
+	return nil
+}
+
+// checkOperand returns an error if the operand of the given childX node,
+// added with no merged value to combine with, does not suit its operator tag.
+// e.g.: replicas: !max abc
+func checkOperand(childX *YamlNode) error {
+	if childX.ntype != NodeTypeScalar {
+		return fmt.Errorf("Operator error at `%s` (%s): %s only applies to scalars, found a %s",
+			NodePath(childX), nodeLocation(childX), childX.tag, nodeTypeNames[childX.ntype])
+	}
+
+	if _, isNumber := parseNumber(unquote(childX.values[0])); childX.tag != TagConcat && !isNumber {
+		return fmt.Errorf("Operator error at `%s` (%s): %s only applies to numbers, found `%s`",
+			NodePath(childX), nodeLocation(childX), childX.tag, unquote(childX.values[0]))
+	}
+
+	return nil
+}
+
+// operateIntegers returns the result of the given operator tag on the given
+// integers, false if it overflows.
+func operateIntegers(tag string, number0 int64, numberX int64) (int64, bool) {
+	switch tag {
+	case TagAdd:
+		result := number0 + numberX
+		if (numberX > 0 && result < number0) || (numberX < 0 && result > number0) {
+			return 0, false
+		}
+
+		return result, true
+	case TagMax:
+		if numberX > number0 {
+			return numberX, true
+		}
+	case TagMin:
+		if numberX < number0 {
+			return numberX, true
+		}
+	}
+
+	return number0, true
+}
+
+// operateFloats returns the result of the given operator tag on the given numbers.
+func operateFloats(tag string, number0 float64, numberX float64) float64 {
+	switch tag {
+	case TagAdd:
+		return number0 + numberX
+	case TagMax:
+		return math.Max(number0, numberX)
+	case TagMin:
+		return math.Min(number0, numberX)
+	}
+
+	return number0
+}
+
+// parseInteger returns the integer of the given value, false if not an integer.
+func parseInteger(value string) (int64, bool) {
+	number, err := strconv.ParseInt(value, 10, 64)
+
+	return number, err == nil
+}
+
+// parseNumber returns the number of the given value, false if not a finite
+// number (inf and nan included).
+func parseNumber(value string) (float64, bool) {
+	number, err := strconv.ParseFloat(value, 64)
+
+	return number, err == nil && !math.IsInf(number, 0) && !math.IsNaN(number)
+}
+
+// formatNumber returns the given number as a value, as an integer unless
+// the operands are floats.
+func formatNumber(number float64, isFloat bool) string {
+	if !isFloat && number == float64(int64(number)) {
+		return strconv.FormatInt(int64(number), 10)
+	}
+
+	return strconv.FormatFloat(number, 'f', -1, 64)
+}
@@ -0,0 +1,75 @@
+package simpleyaml
+
+import (
+	"testing"
+)
+
+func TestMergeOperators(t *testing.T) {
+	runMergeTests(t, []mergeTest{
+		{
+			name:   "numbers combined",
+			inputs: []string{"a: 2\nb: 512\nc: 30\n", "a: !add 3\nb: !max 256\nc: !min 10\n"},
+			output: "a: 5\nb: 512\nc: 10\n",
+		},
+		{
+			name:   "floats combined",
+			inputs: []string{"a: 1.5\n", "a: !add 2\n"},
+			output: "a: 3.5\n",
+		},
+		{
+			name:   "large integers kept",
+			inputs: []string{"a: 9007199254740993\n", "a: !add 0\n"},
+			output: "a: 9007199254740993\n",
+		},
+		{
+			name:   "integer overflow",
+			inputs: []string{"a: 9223372036854775807\n", "a: !add 1\n"},
+			err:    "!add overflows the integers",
+		},
+		{
+			name:   "infinity refused",
+			inputs: []string{"a: 1\n", "a: !max inf\n"},
+			err:    "!max only applies to numbers, found `inf`",
+		},
+		{
+			name:   "nan refused",
+			inputs: []string{"a: nan\n", "a: !min 1\n"},
+			err:    "!min only applies to numbers, found `nan`",
+		},
+		{
+			name:   "number operator without base value",
+			inputs: []string{"a: 1\n", "b: !add 2\n"},
+			output: "a: 1\nb: 2\n",
+		},
+		{
+			name:   "number operator without base value refused",
+			inputs: []string{"a: 1\n", "m: !max abc\n"},
+			err:    "!max only applies to numbers, found `abc`",
+		},
+		{
+			name:   "operator on a list without base value refused",
+			inputs: []string{"a: 1\n", "m: !min\n  - 1\n"},
+			err:    "!min only applies to scalars, found a list",
+		},
+		{
+			name:   "concatenation without base value",
+			inputs: []string{"a: 1\n", "cmd: !concat php\n"},
+			output: "a: 1\ncmd: php\n",
+		},
+		{
+			name:   "strings concatenated",
+			inputs: []string{"cmd: php\n", "cmd: !concat \" -v\"\n"},
+			output: "cmd: php -v\n",
+		},
+		{
+			name:   "operator on a final node",
+			inputs: []string{"replicas: !final 2\n", "replicas: !add 2\n"},
+			err:    "a final node cannot be overridden",
+		},
+		{
+			name:   "operator restating a final node",
+			inputs: []string{"replicas: !final 2\n", "replicas: !max 1\n"},
+			output: "replicas: 2\n",
+		},
+	}, newTestMerger)
+}
@@ -0,0 +1,88 @@
+package simpleyaml
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Pattern keys Tokens
+const (
+	TkKeyWildcard    = "*" // Glob key. e.g.: "*" or "web-*"
+	TkKeyRegexpDelim = "/" // Regexp key. e.g.: /^worker-[0-9]+$/
+)
+
+// isRegexpKey tells if the given (unquoted) key is a regexp. e.g.: /^web-.*$/
+func isRegexpKey(name string) bool {
+	return len(name) > 2 && strings.HasPrefix(name, TkKeyRegexpDelim) &&
+		strings.HasSuffix(name, TkKeyRegexpDelim)
+}
+
+// keyMatcher returns the function matching a key with the given pattern key.
+func keyMatcher(node *YamlNode) (func(name string) bool, error) {
+	pattern := unquote(node.name)
+
+	if isRegexpKey(pattern) {
+		re, err := regexp.Compile(strings.TrimSuffix(strings.TrimPrefix(pattern, TkKeyRegexpDelim), TkKeyRegexpDelim))
+		if err != nil {
+			return nil, fmt.Errorf("Pattern error at `%s` (%s): %s", NodePath(node), nodeLocation(node), err)
+		}
+
+		return func(name string) bool { return re.MatchString(unquote(name)) }, nil
+	}
+
+	_, err := matchName(pattern, "")
+	if err != nil {
+		return nil, fmt.Errorf("Pattern error at `%s` (%s): %s", NodePath(node), nodeLocation(node), err)
+	}
+
+	return func(name string) bool {
+		matched, _ := matchName(pattern, unquote(name))
+		return matched
+	}, nil
+}
+
+// mergePatternKey merges the !match tagged childX node into every child of
+// parent0 matching its pattern key. A pattern key matching nothing is reported
+// and not added, neither are the deletions of nodes missing from a matched child.
+func (ym *YamlMerger) mergePatternKey(parent0 *YamlNode, childX *YamlNode) error {
+	match, err := keyMatcher(childX)
+	if err != nil {
+		return err
+	}
+
+	matched := false
+
+	if parent0.ntype != NodeTypeList {
+		// Copy: the matched children may be removed during the merge
+		children0 := append([]*YamlNode(nil), parent0.children...)
+
+		for _, child0 := range children0 {
+			if !match(child0.name) {
+				continue
+			}
+
+			// Detached copy renamed as the matched child: the merge must not
+			// continue on childX siblings
+			rootX := CreateRootNode()
+			nodeX := NewChildNode(&rootX)
+			CopyNode(childX, nodeX)
+			nodeX.name = child0.name
+			nodeX.tag = ""
+			matched = true
+
+			ym.patternDepth++
+			err = ym.mergeNodes(parent0, nodeX)
+			ym.patternDepth--
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if !matched {
+		ym.diagnose(unquotePath(childNodePath(parent0, childX.name)), childX, "Pattern key matches no key, ignored")
+	}
+
+	return ym.mergeNextNode(parent0, childX)
+}
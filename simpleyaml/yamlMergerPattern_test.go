@@ -0,0 +1,95 @@
+package simpleyaml
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMergePatternKeys(t *testing.T) {
+	base := "services:\n" +
+		"  web-1:\n    image: nginx\n    debug: true\n" +
+		"  web-2:\n    image: nginx\n" +
+		"  worker-1:\n    image: php\n"
+
+	tests := []struct {
+		name        string
+		overlay     string
+		output      string
+		err         string
+		diagnostics []string // Paths of the "matches no key" warnings
+	}{
+		{
+			name:    "glob",
+			overlay: "services:\n  \"web-*\": !match\n    restart: always\n",
+			output: "services:\n" +
+				"  web-1:\n    image: nginx\n    debug: true\n    restart: always\n" +
+				"  web-2:\n    image: nginx\n    restart: always\n" +
+				"  worker-1:\n    image: php\n",
+		},
+		{
+			name:    "regexp",
+			overlay: "services:\n  /^(web-2|worker-[0-9]+)$/: !match\n    image: alpine\n",
+			output: "services:\n" +
+				"  web-1:\n    image: nginx\n    debug: true\n" +
+				"  web-2:\n    image: alpine\n" +
+				"  worker-1:\n    image: alpine\n",
+		},
+		{
+			name:    "deletion where it exists",
+			overlay: "services:\n  \"*\": !match\n    debug: nil\n",
+			output: "services:\n" +
+				"  web-1:\n    image: nginx\n" +
+				"  web-2:\n    image: nginx\n" +
+				"  worker-1:\n    image: php\n",
+		},
+		{
+			name:        "no match",
+			overlay:     "services:\n  \"db-*\": !match\n    image: mysql\n",
+			output:      base,
+			diagnostics: []string{"services.db-*"},
+		},
+		{
+			name:    "untagged key kept as is",
+			overlay: "services:\n  \"web-*\":\n    image: mysql\n",
+			output: base +
+				"  \"web-*\":\n    image: mysql\n",
+		},
+		{
+			name:    "invalid regexp",
+			overlay: "services:\n  /^web-(/: !match\n    image: alpine\n",
+			err:     "Pattern error at `services./^web-(/`",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			yamls := []*YamlNode{parseYaml(t, "input1.yml", base), parseYaml(t, "input2.yml", test.overlay)}
+
+			merged, diagnostics, err := newTestMerger(yamls).Merge()
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected error containing %q, got %v", test.err, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if output := writeYamlStream(t, merged); output != test.output {
+				t.Fatalf("expected:\n%s\ngot:\n%s", test.output, output)
+			}
+
+			if len(diagnostics) != len(test.diagnostics) {
+				t.Fatalf("expected warnings at %v, got %v", test.diagnostics, diagnostics)
+			}
+
+			for i, diagnostic := range diagnostics {
+				if diagnostic.Path != test.diagnostics[i] {
+					t.Fatalf("expected a warning at `%s`, got %s", test.diagnostics[i], diagnostic)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package simpleyaml
+
+import (
+	"fmt"
+)
+
+// Type conflict policies (node types mismatch between base and overlay)
+const (
+	PolicyError              uint = iota // Fail the merge
+	PolicyOverlayWins                    // Overwrite the base node
+	PolicyBaseWins                       // Keep the base node
+	PolicyWrapScalarIntoList             // Merge a scalar as a single item list
+	PolicyWarn                           // Overwrite the base node and report a diagnostic
+)
+
+// conflictPolicyNames is the name of the type conflict policies.
+var conflictPolicyNames = map[string]uint{
+	"error":                 PolicyError,
+	"overlay-wins":          PolicyOverlayWins,
+	"base-wins":             PolicyBaseWins,
+	"wrap-scalar-into-list": PolicyWrapScalarIntoList,
+	"warn":                  PolicyWarn,
+}
+
+// mergePresets is the merge rules sets [preset name => configuration function].
+var mergePresets = map[string]func(ym *YamlMerger){
+	"compose":    applyComposePreset,
+	"kubernetes": applyKubernetesPreset,
+}
+
+// pathPolicy is a type conflict policy applied to the paths matching a pattern.
+type pathPolicy struct {
+	pattern string
+	policy  uint
+}
+
+// MergeDiagnostic is a non-fatal issue found during the merge.
+type MergeDiagnostic struct {
+	Path    string // Path of the merged node
+	Source  string // File of the overlay node
+	Line    uint   // Line of the overlay node
+	Message string
+}
+
+// String returns the diagnostic formatted for display.
+func (md *MergeDiagnostic) String() string {
+	return fmt.Sprintf("%s at `%s` (%s:%d)", md.Message, md.Path, md.Source, md.Line)
+}
+
+// ParseConflictPolicy returns the type conflict policy of the given name.
+// e.g.: overlay-wins
+func ParseConflictPolicy(name string) (uint, error) {
+	policy, found := conflictPolicyNames[name]
+	if !found {
+		return 0, fmt.Errorf("Unknown conflict policy `%s`", name)
+	}
+
+	return policy, nil
+}
+
+// ApplyPreset configures the merger with the given set of merge rules.
+// e.g.: compose
+// The path patterns already set take precedence over the preset ones.
+func (ym *YamlMerger) ApplyPreset(name string) error {
+	preset, found := mergePresets[name]
+	if !found {
+		return fmt.Errorf("Unknown merge preset `%s`", name)
+	}
+
+	preset(ym)
+
+	return nil
+}
+
+// SetConflictPolicy sets the global type conflict policy.
+// Default: PolicyError in strict mode, PolicyOverlayWins otherwise.
+func (ym *YamlMerger) SetConflictPolicy(policy uint) {
+	ym.conflictPolicy = policy
+}
+
+// SetPathConflictPolicy sets the type conflict policy of the paths matching
+// the given pattern. e.g.: services.*.ports
+// The first matching pattern wins over the global policy.
+func (ym *YamlMerger) SetPathConflictPolicy(pattern string, policy uint) {
+	ym.pathPolicies = append(ym.pathPolicies, pathPolicy{pattern, policy})
+}
+
+// conflictPolicyFor returns the type conflict policy of the given path.
+func (ym *YamlMerger) conflictPolicyFor(path string) uint {
+	for _, pp := range ym.pathPolicies {
+		if MatchPath(pp.pattern, path) {
+			return pp.policy
+		}
+	}
+
+	return ym.conflictPolicy
+}
+
+// wrapScalarIntoList merges the given scalar and list nodes as lists.
+// Returns false if the nodes are not a scalar and a list.
+func (ym *YamlMerger) wrapScalarIntoList(child0 *YamlNode, childX *YamlNode) (bool, error) {
+	if child0.ntype == NodeTypeScalar && childX.ntype == NodeTypeList {
+		child0.ntype = NodeTypeList
+		child0.tag = ""
+
+		return true, ym.mergeNodesList(child0, childX)
+	}
+
+	if child0.ntype == NodeTypeList && childX.ntype == NodeTypeScalar {
+		wrappedX := new(YamlNode)
+		CopyNode(childX, wrappedX)
+		wrappedX.ntype = NodeTypeList
+
+		return true, ym.mergeNodesList(child0, wrappedX)
+	}
+
+	return false, nil
+}
+
+// diagnose adds a diagnostic for the given overlay node.
+func (ym *YamlMerger) diagnose(path string, childX *YamlNode, msg string) {
+	ym.diagnostics = append(ym.diagnostics, &MergeDiagnostic{
+		Path:    path,
+		Source:  childX.source,
+		Line:    childX.line,
+		Message: msg,
+	})
+}
@@ -0,0 +1,136 @@
+package simpleyaml
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConflictPolicies(t *testing.T) {
+	base := "port: 80\nhosts:\n  - web\n"
+	overlay := "port:\n  - \"8080\"\nhosts: db\n"
+
+	tests := []struct {
+		name        string
+		configure   func(ym *YamlMerger)
+		output      string
+		err         string
+		diagnostics []string
+	}{
+		{
+			name: "strict mode",
+			err:  "Fatal error: [Strict Mode] Different node type found: port / port at `port`",
+		},
+		{
+			name:      "overlay wins",
+			configure: func(ym *YamlMerger) { ym.SetConflictPolicy(PolicyOverlayWins) },
+			output:    "port:\n  - \"8080\"\nhosts: db\n",
+		},
+		{
+			name:      "base wins",
+			configure: func(ym *YamlMerger) { ym.SetConflictPolicy(PolicyBaseWins) },
+			output:    "port: 80\nhosts:\n  - web\n",
+		},
+		{
+			name:      "wrap scalar into list",
+			configure: func(ym *YamlMerger) { ym.SetConflictPolicy(PolicyWrapScalarIntoList) },
+			output:    "port:\n  - 80\n  - \"8080\"\nhosts:\n  - web\n  - db\n",
+		},
+		{
+			name:        "warn",
+			configure:   func(ym *YamlMerger) { ym.SetConflictPolicy(PolicyWarn) },
+			output:      "port:\n  - \"8080\"\nhosts: db\n",
+			diagnostics: []string{"port", "hosts"},
+		},
+		{
+			name: "first matching path wins",
+			configure: func(ym *YamlMerger) {
+				ym.SetPathConflictPolicy("host*", PolicyBaseWins)
+				ym.SetPathConflictPolicy("*", PolicyWarn)
+			},
+			output:      "port:\n  - \"8080\"\nhosts:\n  - web\n",
+			diagnostics: []string{"port"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ym := newTestMerger([]*YamlNode{parseYaml(t, "input1.yml", base), parseYaml(t, "input2.yml", overlay)})
+			if test.configure != nil {
+				test.configure(ym)
+			}
+
+			merged, diagnostics, err := ym.Merge()
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected error containing %q, got %v", test.err, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if output := writeYamlStream(t, merged); output != test.output {
+				t.Fatalf("expected:\n%s\ngot:\n%s", test.output, output)
+			}
+
+			if len(diagnostics) != len(test.diagnostics) {
+				t.Fatalf("expected diagnostics at %v, got %v", test.diagnostics, diagnostics)
+			}
+
+			for i, diagnostic := range diagnostics {
+				if diagnostic.Path != test.diagnostics[i] || !strings.HasSuffix(diagnostic.Source, "input2.yml") {
+					t.Fatalf("expected a diagnostic at `%s`, got %s", test.diagnostics[i], diagnostic)
+				}
+			}
+		})
+	}
+}
+
+func TestWrapScalarIntoListMismatch(t *testing.T) {
+	ym := newTestMerger([]*YamlNode{
+		parseYaml(t, "input1.yml", "a: 1\n"),
+		parseYaml(t, "input2.yml", "a:\n  b: 2\n"),
+	})
+	ym.SetConflictPolicy(PolicyWrapScalarIntoList)
+
+	_, _, err := ym.Merge()
+	if err == nil || !strings.Contains(err.Error(), "only a scalar and a list can be wrapped") {
+		t.Fatalf("expected a wrap error, got %v", err)
+	}
+}
+
+func TestParseConflictPolicy(t *testing.T) {
+	policy, err := ParseConflictPolicy("wrap-scalar-into-list")
+	if err != nil || policy != PolicyWrapScalarIntoList {
+		t.Fatalf("expected PolicyWrapScalarIntoList, got %d, %v", policy, err)
+	}
+
+	_, err = ParseConflictPolicy("merge")
+	if err == nil || err.Error() != "Unknown conflict policy `merge`" {
+		t.Fatalf("expected an unknown policy error, got %v", err)
+	}
+}
+
+func TestPresetPathPrecedence(t *testing.T) {
+	ym := newTestMerger([]*YamlNode{
+		parseYaml(t, "input1.yml", "services:\n  php:\n    command: php-fpm\n"),
+		parseYaml(t, "input2.yml", "services:\n  php:\n    command:\n      - php\n"),
+	})
+	ym.SetPathConflictPolicy("services.*.command", PolicyBaseWins)
+
+	if err := ym.ApplyPreset("compose"); err != nil {
+		t.Fatal(err)
+	}
+
+	merged, _, err := ym.Merge()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "services:\n  php:\n    command: php-fpm\n"
+	if output := writeYamlStream(t, merged); output != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, output)
+	}
+}
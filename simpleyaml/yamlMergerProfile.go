@@ -0,0 +1,162 @@
+package simpleyaml
+
+import (
+	"regexp"
+)
+
+// Profiles Tokens
+const (
+	TkProfileDelim     = "@"        // Profile variant of a key. e.g.: image@prod
+	profilesSectionKey = "profiles" // Top-level profiles section. e.g.: profiles: {prod: {...}}
+)
+
+// profileKeyRegexp matches the profile variant keys. e.g.: image@prod
+var profileKeyRegexp = regexp.MustCompile(`^([^@]+)@([A-Za-z0-9_-]+)$`)
+
+// SetProfiles sets the profiles to fold in. e.g.: prod
+// The variants of the other profiles are removed. Without profiles, the YAMLs
+// are merged as is (variant keys and profiles section included).
+func (ym *YamlMerger) SetProfiles(profiles ...string) {
+	ym.profiles = profiles
+}
+
+// isActiveProfile tells if the given profile is selected.
+func (ym *YamlMerger) isActiveProfile(profile string) bool {
+	for _, p := range ym.profiles {
+		if p == profile {
+			return true
+		}
+	}
+
+	return false
+}
+
+// foldProfiles returns the given YAML with the variants of the selected
+// profiles merged in: the `key@profile` keys over their key, then the sections
+// of the top-level `profiles` mapping over the YAML. Profile markers are removed.
+// The YAML is returned as is if no profile is selected.
+func (ym *YamlMerger) foldProfiles(yaml *YamlNode) (*YamlNode, error) {
+	if len(ym.profiles) == 0 {
+		return yaml, nil
+	}
+
+	base := new(YamlNode)
+	CopyNode(yaml, base)
+
+	layers := []*YamlNode{base}
+
+	section := TraverseFindChild(base, profilesSectionKey)
+	if section != nil && section.ntype == NodeTypeChildren {
+		RemoveChildNode(section)
+	} else {
+		section = nil
+	}
+
+	variants := CreateRootNode()
+	variants.source = yaml.source
+	if ym.extractProfileVariants(base, &variants) {
+		layers = append(layers, &variants)
+	}
+
+	if section != nil {
+		for _, profile := range section.children {
+			if !ym.isActiveProfile(profile.name) {
+				continue
+			}
+
+			layer := CreateRootNode()
+			layer.source = yaml.source
+			CopyNode(profile, &layer)
+
+			// Nested variants of the section
+			sectionVariants := CreateRootNode()
+			sectionVariants.source = yaml.source
+			hasVariants := ym.extractProfileVariants(&layer, &sectionVariants)
+
+			layers = append(layers, &layer)
+			if hasVariants {
+				layers = append(layers, &sectionVariants)
+			}
+		}
+	}
+
+	if len(layers) == 1 {
+		return base, nil
+	}
+
+	merger := ym.newSubMerger(layers)
+	folded, _, err := merger.Merge()
+
+	return folded, err
+}
+
+// extractProfileVariants removes recursively the profile variant keys of the
+// given node, and adds the selected ones (renamed as their key) at the same
+// path of the variants node. Returns true if a variant was added.
+// The list items are not searched.
+func (ym *YamlMerger) extractProfileVariants(node *YamlNode, variants *YamlNode) bool {
+	found := false
+	var children []*YamlNode
+
+	for _, child := range node.children {
+		match := profileKeyRegexp.FindStringSubmatch(child.name)
+		if match == nil {
+			children = append(children, child)
+			continue
+		}
+
+		if ym.isActiveProfile(match[2]) {
+			variant := NewChildNode(variants)
+			CopyNode(child, variant)
+			variant.name = match[1]
+			found = true
+		}
+	}
+
+	node.children = children
+
+	for _, child := range node.children {
+		if child.ntype != NodeTypeChildren || len(child.children) == 0 {
+			continue
+		}
+
+		subVariants := new(YamlNode)
+		subVariants.name = child.name
+		subVariants.ntype = NodeTypeChildren
+		subVariants.source = child.source
+		subVariants.line = child.line
+
+		if ym.extractProfileVariants(child, subVariants) {
+			existing := TraverseFindChild(variants, child.name)
+			if existing == nil {
+				existing = NewChildNode(variants)
+				CopyNode(subVariants, existing)
+			} else {
+				for _, subVariant := range subVariants.children {
+					CopyNode(subVariant, NewChildNode(existing))
+				}
+			}
+
+			found = true
+		}
+	}
+
+	return found
+}
+
+// newSubMerger returns a new YamlMerger with the merge rules of the merger,
+// for an intermediate merge.
+func (ym *YamlMerger) newSubMerger(yamls []*YamlNode) *YamlMerger {
+	merger := NewMerger(yamls, ym.delTk, ym.dplMap, ym.strictMode)
+
+	merger.listModes = ym.listModes
+	merger.conflictPolicy = ym.conflictPolicy
+	merger.pathPolicies = ym.pathPolicies
+	merger.normalizedPaths = ym.normalizedPaths
+	merger.mergeKeys = ym.mergeKeys
+	merger.strategicMerge = ym.strategicMerge
+	merger.pathStrategies = ym.pathStrategies
+	merger.partialMerge = true
+
+	return merger
+}
@@ -0,0 +1,55 @@
+package simpleyaml
+
+import (
+	"testing"
+)
+
+func TestFoldProfiles(t *testing.T) {
+	input := "php:\n" +
+		"  image: php-dev\n" +
+		"  image@prod: php\n" +
+		"  image@eu: php-eu\n" +
+		"admin@example.com: owner\n" +
+		"profiles:\n" +
+		"  prod:\n" +
+		"    php:\n" +
+		"      restart: always\n"
+
+	newProfileMerger := func(profiles ...string) func(yamls []*YamlNode) *YamlMerger {
+		return func(yamls []*YamlNode) *YamlMerger {
+			merger := newTestMerger(yamls)
+			merger.SetProfiles(profiles...)
+
+			return merger
+		}
+	}
+
+	runMergeTests(t, []mergeTest{
+		{
+			name:   "no profile",
+			inputs: []string{input, "x: 1\n"},
+			output: input + "x: 1\n",
+		},
+	}, newProfileMerger())
+
+	runMergeTests(t, []mergeTest{
+		{
+			name:   "profile folded",
+			inputs: []string{input, "x: 1\n"},
+			output: "php:\n  image: php\n  restart: always\nadmin@example.com: owner\nx: 1\n",
+		},
+		{
+			name:   "overlay variant folded",
+			inputs: []string{input, "php:\n  image@prod: php:8\n"},
+			output: "php:\n  image: php:8\n  restart: always\nadmin@example.com: owner\n",
+		},
+	}, newProfileMerger("prod"))
+
+	runMergeTests(t, []mergeTest{
+		{
+			name:   "profiles folded in order",
+			inputs: []string{input, "x: 1\n"},
+			output: "php:\n  image: php-eu\n  restart: always\nadmin@example.com: owner\nx: 1\n",
+		},
+	}, newProfileMerger("prod", "eu"))
+}
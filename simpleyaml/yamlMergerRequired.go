@@ -0,0 +1,43 @@
+package simpleyaml
+
+import (
+	"fmt"
+	"strings"
+)
+
+// checkRequired returns an error listing the required placeholders of the
+// given merged YAML not set by any YAML.
+func (ym *YamlMerger) checkRequired(yaml *YamlNode) error {
+	var unset []string
+	collectRequired(yaml, &unset)
+
+	if len(unset) == 0 {
+		return nil
+	}
+
+	var files []string
+	for _, yaml := range ym.yamls {
+		files = append(files, yaml.source)
+	}
+
+	return fmt.Errorf("Required error: %d value(s) not set by the merged files (%s):\n%s",
+		len(unset), strings.Join(files, ", "), strings.Join(unset, "\n"))
+}
+
+// collectRequired adds recursively the required placeholders of the given node
+// to the given list. e.g.: `database.password` (base.yml:3): set per environment
+func collectRequired(node *YamlNode, unset *[]string) {
+	for _, child := range node.children {
+		if child.tag != TagRequired {
+			collectRequired(child, unset)
+			continue
+		}
+
+		line := fmt.Sprintf("  `%s` (%s)", NodePath(child), nodeLocation(child))
+		if child.ntype == NodeTypeScalar && unquote(child.values[0]) != "" {
+			line += ": " + unquote(child.values[0])
+		}
+
+		*unset = append(*unset, line)
+	}
+}
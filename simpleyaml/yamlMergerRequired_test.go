@@ -0,0 +1,56 @@
+package simpleyaml
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRequiredPlaceholders(t *testing.T) {
+	base := "database:\n" +
+		"  host: db\n" +
+		"  password: !required set per environment\n" +
+		"  options: !required\n"
+
+	t.Run("set by a later layer", func(t *testing.T) {
+		yamls := []*YamlNode{
+			parseYaml(t, "input1.yml", base),
+			parseYaml(t, "input2.yml", "database:\n  password: secret\n"),
+			parseYaml(t, "input3.yml", "database:\n  options:\n    ssl: true\n"),
+		}
+
+		merged, _, err := newTestMerger(yamls).Merge()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := "database:\n  host: db\n  password: secret\n  options:\n    ssl: true\n"
+		if output := writeYamlStream(t, merged); output != expected {
+			t.Fatalf("expected:\n%s\ngot:\n%s", expected, output)
+		}
+	})
+
+	t.Run("not set", func(t *testing.T) {
+		yamls := []*YamlNode{
+			parseYaml(t, "input1.yml", base),
+			parseYaml(t, "input2.yml", "database:\n  host: db.prod\n  password: !default secret\n"),
+		}
+
+		_, _, err := newTestMerger(yamls).Merge()
+		if err == nil {
+			t.Fatal("expected a required error")
+		}
+
+		lines := strings.Split(err.Error(), "\n")
+		if len(lines) != 3 || !strings.HasPrefix(lines[0], "Required error: 2 value(s) not set by the merged files") {
+			t.Fatalf("expected 2 unset values, got %v", err)
+		}
+
+		if !strings.HasPrefix(lines[1], "  `database.password` (") || !strings.HasSuffix(lines[1], "input1.yml:3): set per environment") {
+			t.Fatalf("expected the password placeholder with its message, got %q", lines[1])
+		}
+
+		if !strings.HasPrefix(lines[2], "  `database.options` (") || !strings.HasSuffix(lines[2], "input1.yml:4)") {
+			t.Fatalf("expected the options placeholder, got %q", lines[2])
+		}
+	})
+}
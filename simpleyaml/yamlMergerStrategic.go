@@ -0,0 +1,341 @@
+package simpleyaml
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Kubernetes strategic merge patch directives
+const (
+	tkPatch           = "$patch"
+	tkSetElementOrder = "$setElementOrder/"
+	tkDeleteFromList  = "$deleteFromPrimitiveList/"
+	patchDelete       = "delete"
+	patchReplace      = "replace"
+)
+
+// kubernetesMergeKeys is the merge keys of the well-known Kubernetes lists
+// [list name => item keys (first found)].
+var kubernetesMergeKeys = map[string][]string{
+	"containers":                {"name"},
+	"initContainers":            {"name"},
+	"ephemeralContainers":       {"name"},
+	"ports":                     {"containerPort", "port"},
+	"volumeMounts":              {"mountPath"},
+	"volumeDevices":             {"devicePath"},
+	"volumes":                   {"name"},
+	"env":                       {"name"},
+	"imagePullSecrets":          {"name"},
+	"hostAliases":               {"ip"},
+	"readinessGates":            {"conditionType"},
+	"resourceClaims":            {"name"},
+	"topologySpreadConstraints": {"topologyKey"},
+	"conditions":                {"type"},
+}
+
+// elementOrder is a pending $setElementOrder directive.
+type elementOrder struct {
+	parent   *YamlNode // Parent of the list in the merged YAML
+	listName string
+	order    *YamlNode // Expected order of the list items
+}
+
+// applyKubernetesPreset configures the given merger with the Kubernetes
+// strategic merge patch rules.
+func applyKubernetesPreset(ym *YamlMerger) {
+	for k, v := range kubernetesMergeKeys {
+		ym.SetMergeKey(k, v...)
+	}
+
+	ym.strategicMerge = true
+}
+
+// SetMergeKey sets the keys identifying the mapping items of the lists with
+// the given name (the first key found in an item is used).
+// e.g.: containers by name
+// Lists of mappings without merge key are replaced.
+func (ym *YamlMerger) SetMergeKey(listName string, keys ...string) {
+	ym.mergeKeys[listName] = keys
+}
+
+// hasListItems tells if the given list node has mapping items.
+func hasListItems(node *YamlNode) bool {
+	return node.ntype == NodeTypeList && len(node.children) > 0
+}
+
+// mergeListItems merges the mapping items of the given two list nodes
+// by merge key.
+func (ym *YamlMerger) mergeListItems(node *YamlNode, node2 *YamlNode) error {
+	keys, hasKeys := ym.mergeKeys[node2.name]
+
+	if !hasKeys || (ym.strategicMerge && listPatch(node2) == patchReplace) {
+		node.values = node2.values
+		node.children = nil
+
+		for _, itemX := range node2.children {
+			if ym.strategicMerge && nodePatch(itemX) != "" {
+				continue
+			}
+
+			CopyNode(itemX, NewChildNode(node))
+		}
+
+		return nil
+	}
+
+	if len(node2.values) > 0 {
+		return fmt.Errorf("Fatal error: Different list item types found: %s / %s at `%s` (%s), "+
+			"scalar items can't be merged into mapping items", node.name, node2.name, NodePath(node),
+			nodeLocation(node2))
+	}
+
+	for _, itemX := range node2.children {
+		patch := ""
+		if ym.strategicMerge {
+			patch = nodePatch(itemX)
+		}
+
+		item0 := findListItem(node, itemX, keys)
+
+		if patch == patchDelete {
+			if item0 != nil {
+				RemoveChildNode(item0)
+			}
+			continue
+		}
+
+		if item0 == nil {
+			CopyNode(itemX, NewChildNode(node))
+			continue
+		}
+
+		if patch == patchReplace {
+			ReplaceNode(item0, itemX)
+			continue
+		}
+
+		// Detached copy: the merge must not continue on itemX siblings
+		nodeX := new(YamlNode)
+		CopyNode(itemX, nodeX)
+
+		firstX := TraverseDown(nodeX)
+		if firstX != nil {
+			err := ym.mergeNodes(item0, firstX)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	node.source = node2.source
+	node.line = node2.line
+
+	return nil
+}
+
+// findListItem returns the item of the given list node matching the given item
+// by merge key.
+func findListItem(node *YamlNode, item *YamlNode, keys []string) *YamlNode {
+	key, value := listItemKey(item, keys)
+	if key == "" {
+		return nil
+	}
+
+	for _, item0 := range node.children {
+		key0, value0 := listItemKey(item0, keys)
+		if key0 == key && value0 == value {
+			return item0
+		}
+	}
+
+	return nil
+}
+
+// listItemKey returns the first merge key found in the given item and its value.
+func listItemKey(item *YamlNode, keys []string) (string, string) {
+	for _, key := range keys {
+		child := TraverseFindChild(item, key)
+		if child != nil && child.ntype == NodeTypeScalar {
+			return key, unquote(child.values[0])
+		}
+	}
+
+	return "", ""
+}
+
+// nodePatch returns the $patch directive of the given mapping node (if any).
+func nodePatch(node *YamlNode) string {
+	patch := TraverseFindChild(node, tkPatch)
+	if patch == nil || patch.ntype != NodeTypeScalar {
+		return ""
+	}
+
+	return unquote(patch.values[0])
+}
+
+// listPatch returns the $patch directive of the given list node (if any).
+// e.g.: - $patch: replace
+func listPatch(node *YamlNode) string {
+	for _, item := range node.children {
+		if len(item.children) == 1 {
+			patch := nodePatch(item)
+			if patch != "" {
+				return patch
+			}
+		}
+	}
+
+	return ""
+}
+
+// mergeNodesPatch applies the $patch directive of the childX mapping to the
+// child0 node. Returns false if there is no directive to apply.
+func (ym *YamlMerger) mergeNodesPatch(child0 *YamlNode, childX *YamlNode) bool {
+	switch nodePatch(childX) {
+	case patchDelete:
+		RemoveChildNode(child0)
+	case patchReplace:
+		ReplaceNode(child0, childX)
+	default:
+		return false
+	}
+
+	return true
+}
+
+// hasPrimitiveListDirective tells if the given primitive list node has a
+// sibling $setElementOrder or $deleteFromPrimitiveList directive (the list is
+// then merged instead of replaced).
+func hasPrimitiveListDirective(node *YamlNode) bool {
+	return TraverseFindSibling(node, tkSetElementOrder+node.name) != nil ||
+		TraverseFindSibling(node, tkDeleteFromList+node.name) != nil
+}
+
+// deleteFromPrimitiveList removes the values of the given
+// $deleteFromPrimitiveList directive from its list.
+// e.g.: $deleteFromPrimitiveList/finalizers: [a]
+func (ym *YamlMerger) deleteFromPrimitiveList(parent0 *YamlNode, childX *YamlNode) {
+	list := TraverseFindChild(parent0, strings.TrimPrefix(childX.name, tkDeleteFromList))
+	if list == nil || list.ntype != NodeTypeList {
+		return
+	}
+
+	deleteFromList(list, childX)
+	delete(ym.mappedLists, nodePointerToInt(list))
+}
+
+// deleteFromList removes the values of the given directive from the given list.
+func deleteFromList(list *YamlNode, directive *YamlNode) {
+	var values []string
+	for _, value := range list.values {
+		if !containsValue(directive.values, value) {
+			values = append(values, value)
+		}
+	}
+
+	list.values = values
+}
+
+// addElementOrder registers the given $setElementOrder directive, applied once
+// the current YAML is merged.
+func (ym *YamlMerger) addElementOrder(parent0 *YamlNode, childX *YamlNode) {
+	ym.elementOrders = append(ym.elementOrders, &elementOrder{
+		parent:   parent0,
+		listName: strings.TrimPrefix(childX.name, tkSetElementOrder),
+		order:    childX,
+	})
+}
+
+// applyElementOrders reorders the lists of the pending $setElementOrder directives.
+func (ym *YamlMerger) applyElementOrders() {
+	for _, eo := range ym.elementOrders {
+		list := TraverseFindChild(eo.parent, eo.listName)
+		if list != nil && list.ntype == NodeTypeList {
+			ym.orderList(list, eo.order)
+		}
+	}
+
+	ym.elementOrders = nil
+}
+
+// orderList reorders the items of the given list as the given expected order.
+// The items missing from the expected order keep their relative order, at the end.
+func (ym *YamlMerger) orderList(list *YamlNode, order *YamlNode) {
+	var values []string
+	for _, value := range order.values {
+		for _, value0 := range list.values {
+			if value0 == value {
+				values = append(values, value0)
+			}
+		}
+	}
+
+	var children []*YamlNode
+	keys := ym.mergeKeys[list.name]
+	for _, orderItem := range order.children {
+		item0 := findListItem(list, orderItem, keys)
+		if item0 != nil {
+			children = append(children, item0)
+		}
+	}
+
+	list.values = appendMissing(values, list.values)
+	list.children = appendMissingNodes(children, list.children)
+}
+
+// listDirectiveTarget returns the name of the list changed by the given
+// $setElementOrder or $deleteFromPrimitiveList directive name ("" if none).
+func listDirectiveTarget(name string) string {
+	for _, prefix := range []string{tkSetElementOrder, tkDeleteFromList} {
+		if strings.HasPrefix(name, prefix) {
+			return strings.TrimPrefix(name, prefix)
+		}
+	}
+
+	return ""
+}
+
+// appendMissing returns the given list with the missing items of the other list.
+func appendMissing(list []string, list2 []string) []string {
+	for _, item2 := range list2 {
+		found := false
+		for _, item := range list {
+			if item == item2 {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			list = append(list, item2)
+		}
+	}
+
+	return list
+}
+
+// appendMissingNodes returns the given nodes with the missing nodes of the other list.
+func appendMissingNodes(nodes []*YamlNode, nodes2 []*YamlNode) []*YamlNode {
+	for _, node2 := range nodes2 {
+		found := false
+		for _, node := range nodes {
+			if node == node2 {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			nodes = append(nodes, node2)
+		}
+	}
+
+	return nodes
+}
+
+// isStrategicDirective tells if the given node is a strategic merge patch
+// directive (i.e. not to be written).
+func isStrategicDirective(node *YamlNode) bool {
+	return node.name == tkPatch || strings.HasPrefix(node.name, tkSetElementOrder) ||
+		strings.HasPrefix(node.name, tkDeleteFromList)
+}
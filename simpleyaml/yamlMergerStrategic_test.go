@@ -0,0 +1,204 @@
+package simpleyaml
+
+import (
+	"strings"
+	"testing"
+)
+
+// newKubernetesMerger returns a merger with the Kubernetes preset.
+func newKubernetesMerger(yamls []*YamlNode) *YamlMerger {
+	merger := newTestMerger(yamls)
+	merger.ApplyPreset("kubernetes")
+
+	return merger
+}
+
+func TestStrategicMergePatch(t *testing.T) {
+	base := "spec:\n" +
+		"  containers:\n" +
+		"    - name: php\n" +
+		"      image: php:8\n" +
+		"      args:\n" +
+		"        - --a\n" +
+		"        - --b\n" +
+		"    - name: nginx\n" +
+		"      image: nginx\n" +
+		"  finalizers:\n" +
+		"    - a\n" +
+		"    - b\n"
+
+	runMergeTests(t, []mergeTest{
+		{
+			name:   "items merged by key",
+			inputs: []string{base, "spec:\n  containers:\n    - name: nginx\n      image: nginx:1.25\n    - name: redis\n      image: redis\n"},
+			output: "spec:\n" +
+				"  containers:\n" +
+				"    - name: php\n" +
+				"      image: php:8\n" +
+				"      args:\n" +
+				"        - --a\n" +
+				"        - --b\n" +
+				"    - name: nginx\n" +
+				"      image: nginx:1.25\n" +
+				"    - name: redis\n" +
+				"      image: redis\n" +
+				"  finalizers:\n" +
+				"    - a\n" +
+				"    - b\n",
+		},
+		{
+			name:   "primitive list replaced",
+			inputs: []string{base, "spec:\n  containers:\n    - name: php\n      args:\n        - --c\n"},
+			output: "spec:\n" +
+				"  containers:\n" +
+				"    - name: php\n" +
+				"      image: php:8\n" +
+				"      args:\n" +
+				"        - --c\n" +
+				"    - name: nginx\n" +
+				"      image: nginx\n" +
+				"  finalizers:\n" +
+				"    - a\n" +
+				"    - b\n",
+		},
+		{
+			name:   "primitive list merged with delete directive",
+			inputs: []string{base, "spec:\n  $deleteFromPrimitiveList/finalizers:\n    - a\n  finalizers:\n    - c\n"},
+			output: "spec:\n" +
+				"  containers:\n" +
+				"    - name: php\n" +
+				"      image: php:8\n" +
+				"      args:\n" +
+				"        - --a\n" +
+				"        - --b\n" +
+				"    - name: nginx\n" +
+				"      image: nginx\n" +
+				"  finalizers:\n" +
+				"    - b\n" +
+				"    - c\n",
+		},
+		{
+			name:   "primitive list ordered",
+			inputs: []string{base, "spec:\n  $setElementOrder/finalizers:\n    - c\n    - b\n    - a\n  finalizers:\n    - c\n"},
+			output: "spec:\n" +
+				"  containers:\n" +
+				"    - name: php\n" +
+				"      image: php:8\n" +
+				"      args:\n" +
+				"        - --a\n" +
+				"        - --b\n" +
+				"    - name: nginx\n" +
+				"      image: nginx\n" +
+				"  finalizers:\n" +
+				"    - c\n" +
+				"    - b\n" +
+				"    - a\n",
+		},
+		{
+			name:   "item deleted",
+			inputs: []string{base, "spec:\n  containers:\n    - name: php\n      $patch: delete\n"},
+			output: "spec:\n" +
+				"  containers:\n" +
+				"    - name: nginx\n" +
+				"      image: nginx\n" +
+				"  finalizers:\n" +
+				"    - a\n" +
+				"    - b\n",
+		},
+		{
+			name:   "list replaced",
+			inputs: []string{base, "spec:\n  containers:\n    - name: redis\n      image: redis\n    - $patch: replace\n"},
+			output: "spec:\n" +
+				"  containers:\n" +
+				"    - name: redis\n" +
+				"      image: redis\n" +
+				"  finalizers:\n" +
+				"    - a\n" +
+				"    - b\n",
+		},
+		{
+			name:   "mapping deleted",
+			inputs: []string{"spec:\n  a:\n    b: 1\n  c: 2\n", "spec:\n  a:\n    $patch: delete\n"},
+			output: "spec:\n  c: 2\n",
+		},
+		{
+			name:   "final mapping not deleted",
+			inputs: []string{"spec:\n  a: !final\n    b: 1\n", "spec:\n  a:\n    $patch: delete\n"},
+			err:    "a final node cannot be deleted",
+		},
+		{
+			name:   "scalar items into mapping items",
+			inputs: []string{base, "spec:\n  containers:\n    - php\n"},
+			err:    "Different list item types found",
+		},
+	}, newKubernetesMerger)
+}
+
+func TestStrategicListDirectivesChecks(t *testing.T) {
+	base := "spec:\n  finalizers:\n    - a\n    - b\n"
+
+	tests := []struct {
+		name    string
+		overlay string
+		err     string
+	}{
+		{
+			name:    "delete from a final list",
+			overlay: "spec:\n  $deleteFromPrimitiveList/finalizers:\n    - a\n",
+			err:     "Final error at `spec.finalizers`",
+		},
+		{
+			name:    "reorder a final list",
+			overlay: "spec:\n  $setElementOrder/finalizers:\n    - b\n    - a\n",
+			err:     "Final error at `spec.finalizers`",
+		},
+		{
+			name:    "final list restated",
+			overlay: "spec:\n  $setElementOrder/finalizers:\n    - a\n    - b\n  $deleteFromPrimitiveList/finalizers:\n    - c\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ym := newKubernetesMerger([]*YamlNode{parseYaml(t, "input1.yml", base), parseYaml(t, "input2.yml", test.overlay)})
+			ym.SetFinalPath("spec.finalizers")
+
+			merged, _, err := ym.Merge()
+			checkDirectiveResult(t, test.err, err)
+
+			if err == nil && writeYamlStream(t, merged) != base {
+				t.Fatalf("expected the final list unchanged, got:\n%s", writeYamlStream(t, merged))
+			}
+		})
+	}
+
+	for _, directive := range []string{"$deleteFromPrimitiveList/finalizers", "$setElementOrder/finalizers"} {
+		t.Run("denied "+directive, func(t *testing.T) {
+			ym := newKubernetesMerger([]*YamlNode{
+				parseYaml(t, "input1.yml", base),
+				parseYaml(t, "input2.yml", "spec:\n  "+directive+":\n    - b\n"),
+			})
+			ym.AddAccessPolicy(&AccessPolicy{FilePattern: "input2.yml", Deny: []string{"spec.finalizers"}})
+
+			_, _, err := ym.Merge()
+			checkDirectiveResult(t, "`spec.finalizers` (", err)
+		})
+	}
+}
+
+// checkDirectiveResult fails if the given error does not contain the
+// expected message ("" if no error is expected).
+func checkDirectiveResult(t *testing.T, expected string, err error) {
+	t.Helper()
+
+	if expected == "" {
+		if err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	if err == nil || !strings.Contains(err.Error(), expected) {
+		t.Fatalf("expected error containing %q, got %v", expected, err)
+	}
+}
@@ -0,0 +1,77 @@
+package simpleyaml
+
+// MergeContext is the context of a node merged by a MergeStrategy.
+type MergeContext struct {
+	Path   string // Path of the merged node. e.g.: services.app.image
+	Source string // File of the overlay node
+	Line   uint   // Line of the overlay node
+}
+
+// MergeStrategy is a custom merge rule of the nodes matching a path pattern.
+type MergeStrategy interface {
+	// Merge returns the merged node of the given base and overlay nodes,
+	// or nil to fall back to the default merge.
+	// The given nodes must not be modified.
+	Merge(base *YamlNode, overlay *YamlNode, ctx MergeContext) (*YamlNode, error)
+}
+
+// MergeStrategyFunc is an adapter to use a function as a MergeStrategy.
+type MergeStrategyFunc func(base *YamlNode, overlay *YamlNode, ctx MergeContext) (*YamlNode, error)
+
+// Merge calls f(base, overlay, ctx).
+func (f MergeStrategyFunc) Merge(base *YamlNode, overlay *YamlNode, ctx MergeContext) (*YamlNode, error) {
+	return f(base, overlay, ctx)
+}
+
+// pathStrategy is a merge strategy applied to the paths matching a pattern.
+type pathStrategy struct {
+	pattern  string
+	strategy MergeStrategy
+}
+
+// SetMergeStrategy sets the merge strategy of the paths matching the given
+// pattern. e.g.: services.*.image
+// The first matching pattern wins.
+func (ym *YamlMerger) SetMergeStrategy(pattern string, strategy MergeStrategy) {
+	ym.pathStrategies = append(ym.pathStrategies, pathStrategy{pattern, strategy})
+}
+
+// mergeStrategyFor returns the merge strategy of the given path (nil if none).
+func (ym *YamlMerger) mergeStrategyFor(path string) MergeStrategy {
+	for _, ps := range ym.pathStrategies {
+		if MatchPath(ps.pattern, path) {
+			return ps.strategy
+		}
+	}
+
+	return nil
+}
+
+// mergeStrategy merges the childX node into the child0 node with the merge
+// strategy of its path. Returns false to fall back to the default merge.
+func (ym *YamlMerger) mergeStrategy(child0 *YamlNode, childX *YamlNode) (bool, error) {
+	path := NodePath(child0)
+
+	strategy := ym.mergeStrategyFor(path)
+	if strategy == nil {
+		return false, nil
+	}
+
+	merged, err := strategy.Merge(child0, childX, MergeContext{path, childX.source, childX.line})
+	if err != nil {
+		return false, err
+	}
+
+	if merged == nil {
+		return false, nil
+	}
+
+	if merged != child0 {
+		name := child0.name
+		delete(ym.mappedLists, nodePointerToInt(child0))
+		ReplaceNode(child0, merged)
+		child0.name = name
+	}
+
+	return true, nil
+}
@@ -0,0 +1,75 @@
+package simpleyaml
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMergeStrategy(t *testing.T) {
+	base := "services:\n  php:\n    image: php:8.1\n    ports:\n      - \"80\"\n"
+	overlay := "services:\n  php:\n    image: php:8.2\n    ports:\n      - \"443\"\n"
+
+	var contexts []MergeContext
+
+	// Keeps the tag of the base image, with the overlay image name
+	keepTag := MergeStrategyFunc(func(base *YamlNode, overlay *YamlNode, ctx MergeContext) (*YamlNode, error) {
+		contexts = append(contexts, ctx)
+
+		tag := base.values[0][strings.Index(base.values[0], ":"):]
+		name := overlay.values[0][:strings.Index(overlay.values[0], ":")]
+
+		return &YamlNode{name: "renamed", ntype: NodeTypeScalar, values: []string{name + tag}}, nil
+	})
+
+	fallback := MergeStrategyFunc(func(base *YamlNode, overlay *YamlNode, ctx MergeContext) (*YamlNode, error) {
+		contexts = append(contexts, ctx)
+
+		return nil, nil
+	})
+
+	ym := newTestMerger([]*YamlNode{parseYaml(t, "input1.yml", base), parseYaml(t, "input2.yml", overlay)})
+	ym.SetMergeStrategy("services.*.image", keepTag)
+	ym.SetMergeStrategy("services.php.*", fallback)
+
+	merged, _, err := ym.Merge()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "services:\n  php:\n    image: php:8.1\n    ports:\n      - \"80\"\n      - \"443\"\n"
+	if output := writeYamlStream(t, merged); output != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, output)
+	}
+
+	if len(contexts) != 2 {
+		t.Fatalf("expected the strategies called for image and ports, got %v", contexts)
+	}
+
+	ctx := contexts[0]
+	if ctx.Path != "services.php.image" || filepath.Base(ctx.Source) != "input2.yml" || ctx.Line != 3 {
+		t.Fatalf("unexpected image context %+v", ctx)
+	}
+
+	if contexts[1].Path != "services.php.ports" {
+		t.Fatalf("expected the first matching pattern to win, got %+v", contexts[1])
+	}
+}
+
+func TestMergeStrategyError(t *testing.T) {
+	failing := MergeStrategyFunc(func(base *YamlNode, overlay *YamlNode, ctx MergeContext) (*YamlNode, error) {
+		return nil, errors.New("downgrade of " + ctx.Path)
+	})
+
+	ym := newTestMerger([]*YamlNode{
+		parseYaml(t, "input1.yml", "version: 2\n"),
+		parseYaml(t, "input2.yml", "version: 1\n"),
+	})
+	ym.SetMergeStrategy("version", failing)
+
+	_, _, err := ym.Merge()
+	if err == nil || err.Error() != "downgrade of version" {
+		t.Fatalf("expected the strategy error, got %v", err)
+	}
+}
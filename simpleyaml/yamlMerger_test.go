@@ -0,0 +1,164 @@
+package simpleyaml
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// parseYamlStream returns the documents of the given YAML content, parsed
+// from a temporary file with the given name.
+func parseYamlStream(t *testing.T, name string, content string) []*YamlNode {
+	t.Helper()
+
+	file := openTestFile(t, name, content)
+	defer file.Close()
+
+	yamls, err := NewParser(file).ParseStream()
+	if err != nil {
+		t.Fatalf("%s: %s", name, err)
+	}
+
+	return yamls
+}
+
+// parseRawYamlStream returns the documents of the given YAML content, parsed
+// with their comments.
+func parseRawYamlStream(t *testing.T, name string, content string) []*YamlNode {
+	t.Helper()
+
+	file := openTestFile(t, name, content)
+	defer file.Close()
+
+	yamls, err := NewRawParser(file).ParseStream()
+	if err != nil {
+		t.Fatalf("%s: %s", name, err)
+	}
+
+	return yamls
+}
+
+// openTestFile returns the opened temporary file with the given name and content.
+func openTestFile(t *testing.T, name string, content string) *os.File {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	err := ioutil.WriteFile(path, []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return file
+}
+
+// parseYaml returns the first document of the given YAML content.
+func parseYaml(t *testing.T, name string, content string) *YamlNode {
+	t.Helper()
+
+	return parseYamlStream(t, name, content)[0]
+}
+
+// writeYamlStream returns the given documents formatted by the writer.
+func writeYamlStream(t *testing.T, yamls ...*YamlNode) string {
+	t.Helper()
+
+	file, err := ioutil.TempFile(t.TempDir(), "output")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	NewWriter(file).WriteStream(yamls)
+
+	content, err := ioutil.ReadFile(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(content)
+}
+
+// mergeTest is a merge of YAML contents, with either the expected output or
+// the expected error (substring).
+type mergeTest struct {
+	name   string
+	inputs []string
+	output string
+	err    string
+}
+
+// runMergeTests merges the inputs of the given tests with the mergers returned
+// by newMerger, and checks their output.
+func runMergeTests(t *testing.T, tests []mergeTest, newMerger func(yamls []*YamlNode) *YamlMerger) {
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var yamls []*YamlNode
+			for i, input := range test.inputs {
+				yamls = append(yamls, parseYaml(t, "input"+string(rune('1'+i))+".yml", input))
+			}
+
+			merged, _, err := newMerger(yamls).Merge()
+			checkMergeResult(t, test, err, func() string {
+				return writeYamlStream(t, merged)
+			})
+		})
+	}
+}
+
+// checkMergeResult checks the given merge error, or the output returned by write.
+func checkMergeResult(t *testing.T, test mergeTest, err error, write func() string) {
+	t.Helper()
+
+	if test.err != "" {
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Fatalf("expected error containing %q, got %v", test.err, err)
+		}
+		return
+	}
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	output := write()
+	if output != test.output {
+		t.Fatalf("unexpected output:\n%s\nexpected:\n%s", output, test.output)
+	}
+}
+
+// newTestMerger returns a strict merger with the "nil" deletion token.
+func newTestMerger(yamls []*YamlNode) *YamlMerger {
+	return NewMerger(yamls, "nil", map[string]string{}, true)
+}
+
+func TestMerge(t *testing.T) {
+	runMergeTests(t, []mergeTest{
+		{
+			name:   "deep merge",
+			inputs: []string{"a:\n  b: 1\n  c: 2\n", "a:\n  c: 3\n  d: 4\n"},
+			output: "a:\n  b: 1\n  c: 3\n  d: 4\n",
+		},
+		{
+			name:   "deletion token",
+			inputs: []string{"a:\n  b: 1\n  c: 2\n", "a:\n  b: nil\n"},
+			output: "a:\n  c: 2\n",
+		},
+		{
+			name:   "lists appended",
+			inputs: []string{"a:\n  - x\n  - y\n", "a:\n  - y\n  - z\n"},
+			output: "a:\n  - x\n  - y\n  - z\n",
+		},
+		{
+			name:   "strict mode type conflict",
+			inputs: []string{"a:\n  b: 1\n", "a: 1\n"},
+			err:    "Different node type found",
+		},
+	}, newTestMerger)
+}
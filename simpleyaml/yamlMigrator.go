@@ -0,0 +1,406 @@
+package simpleyaml
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Migration keys
+const (
+	MigrationVersionKey = "x-migration-version" // Version applied to a migrated YAML
+
+	migrationKeyVersion    = "version"
+	migrationKeySteps      = "steps"
+	migrationKeyTo         = "to"
+	migrationKeyValue      = "value"
+	migrationKeyMatch      = "match"
+	migrationKeyReplace    = "replace"
+	migrationStepMove      = "move"
+	migrationStepRename    = "rename"
+	migrationStepDelete    = "delete"
+	migrationStepSet       = "set"
+	migrationStepTransform = "transform"
+)
+
+// Migration is a versioned list of steps upgrading a YAML schema.
+type Migration struct {
+	Version int
+	steps   []*YamlNode // Steps mapping items. e.g.: {move: a.b, to: a.c}
+	source  string      // Migration file
+}
+
+// YamlMigrator is the struct for upgrading YAMLs with migrations
+type YamlMigrator struct {
+	migrations []*Migration // Sorted by version
+}
+
+// ParseMigration returns the migration of the given migration YAML:
+//
+//	version: 2
+//	steps:
+//	  - move: services.php.environment.XDEBUG_ENABLE
+//	    to: services.php.xdebug.enabled
+//	  - rename: services.*.links
+//	    to: external_links
+//	  - delete: services.*.container_name
+//	  - set: services.php.restart
+//	    value: always
+//	  - transform: services.*.image
+//	    match: '^php:7\.'
+//	    replace: 'php:8.'
+func ParseMigration(yaml *YamlNode) (*Migration, error) {
+	migration := &Migration{source: yaml.source}
+
+	versionNode := TraverseFindChild(yaml, migrationKeyVersion)
+	if versionNode == nil || versionNode.ntype != NodeTypeScalar {
+		return nil, fmt.Errorf("Migration error: missing version in %s", yaml.source)
+	}
+
+	version, err := strconv.Atoi(unquote(versionNode.values[0]))
+	if err != nil || version <= 0 {
+		return nil, fmt.Errorf("Migration error at `%s` (%s): invalid version `%s`",
+			NodePath(versionNode), nodeLocation(versionNode), versionNode.values[0])
+	}
+	migration.Version = version
+
+	stepsNode := TraverseFindChild(yaml, migrationKeySteps)
+	if stepsNode != nil {
+		migration.steps = stepsNode.children
+	}
+
+	for _, step := range migration.steps {
+		action, path, err := migrationStep(step)
+		if err != nil {
+			return nil, err
+		}
+
+		if (action == migrationStepMove || action == migrationStepSet) &&
+			(isPathPattern(path) || isPathPattern(stepArg(step, migrationKeyTo))) {
+			return nil, fmt.Errorf("Migration error at `%s` (%s): `%s` does not support path patterns",
+				NodePath(step), nodeLocation(step), action)
+		}
+	}
+
+	return migration, nil
+}
+
+// migrationStep returns the action and the path of the given step.
+// e.g.: move, services.php.environment.XDEBUG_ENABLE
+func migrationStep(step *YamlNode) (string, string, error) {
+	for _, action := range []string{migrationStepMove, migrationStepRename, migrationStepDelete,
+		migrationStepSet, migrationStepTransform} {
+		node := TraverseFindChild(step, action)
+		if node != nil && node.ntype == NodeTypeScalar {
+			return action, unquote(node.values[0]), nil
+		}
+	}
+
+	return "", "", fmt.Errorf("Migration error at `%s` (%s): unknown step", NodePath(step), nodeLocation(step))
+}
+
+// stepArg returns the scalar argument of the given step ("" if missing).
+func stepArg(step *YamlNode, key string) string {
+	node := TraverseFindChild(step, key)
+	if node == nil || node.ntype != NodeTypeScalar {
+		return ""
+	}
+
+	return unquote(node.values[0])
+}
+
+// NewMigrator returns a new YamlMigrator to upgrade YAMLs with the given migrations.
+func NewMigrator(migrations []*Migration) *YamlMigrator {
+	ym := new(YamlMigrator)
+
+	ym.migrations = append(ym.migrations, migrations...)
+	sort.SliceStable(ym.migrations, func(i, j int) bool {
+		return ym.migrations[i].Version < ym.migrations[j].Version
+	})
+
+	return ym
+}
+
+// Migrate applies the migrations newer than the version of the given YAML,
+// then records the last version applied. Returns the initial and final versions.
+func (ym *YamlMigrator) Migrate(yaml *YamlNode) (int, int, error) {
+	from, err := MigrationVersion(yaml)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	to := from
+	for _, migration := range ym.migrations {
+		if migration.Version <= to {
+			continue
+		}
+
+		for _, step := range migration.steps {
+			err = ym.applyStep(yaml, step)
+			if err != nil {
+				return from, to, err
+			}
+		}
+
+		to = migration.Version
+	}
+
+	if to != from {
+		setMigrationVersion(yaml, to)
+	}
+
+	return from, to, nil
+}
+
+// MigrationVersion returns the migration version recorded in the given YAML
+// (0 if none).
+func MigrationVersion(yaml *YamlNode) (int, error) {
+	versionNode := TraverseFindChild(yaml, MigrationVersionKey)
+	if versionNode == nil {
+		return 0, nil
+	}
+
+	if versionNode.ntype != NodeTypeScalar {
+		return 0, fmt.Errorf("Migration error at `%s` (%s): invalid version",
+			NodePath(versionNode), nodeLocation(versionNode))
+	}
+
+	version, err := strconv.Atoi(unquote(versionNode.values[0]))
+	if err != nil {
+		return 0, fmt.Errorf("Migration error at `%s` (%s): invalid version `%s`",
+			NodePath(versionNode), nodeLocation(versionNode), versionNode.values[0])
+	}
+
+	return version, nil
+}
+
+// setMigrationVersion records the given migration version in the given YAML.
+func setMigrationVersion(yaml *YamlNode, version int) {
+	versionNode := TraverseFindChild(yaml, MigrationVersionKey)
+	if versionNode == nil {
+		versionNode = NewChildNode(yaml)
+		versionNode.name = MigrationVersionKey
+		versionNode.source = yaml.source
+	}
+
+	versionNode.ntype = NodeTypeScalar
+	versionNode.values = []string{strconv.Itoa(version)}
+}
+
+// applyStep applies the given migration step to the given YAML.
+// The steps of missing paths are skipped.
+func (ym *YamlMigrator) applyStep(yaml *YamlNode, step *YamlNode) error {
+	action, path, err := migrationStep(step)
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case migrationStepMove:
+		return migrateMove(yaml, step, path)
+	case migrationStepSet:
+		return migrateSet(yaml, step, path)
+	}
+
+	for _, node := range findMatchingNodes(yaml, path) {
+		switch action {
+		case migrationStepRename:
+			err = migrateRename(step, node)
+		case migrationStepDelete:
+			RemoveChildNode(node)
+		case migrationStepTransform:
+			err = migrateTransform(step, node)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// migrateMove moves the node at the given path to the path of the step `to` key.
+// The missing parents are created.
+func migrateMove(yaml *YamlNode, step *YamlNode, path string) error {
+	node := TraverseFindPath(yaml, path)
+	if node == nil || node == yaml {
+		return nil
+	}
+
+	to := stepArg(step, migrationKeyTo)
+	if to == "" {
+		return fmt.Errorf("Migration error at `%s` (%s): missing `%s`", NodePath(step), nodeLocation(step), migrationKeyTo)
+	}
+
+	if TraverseFindPath(yaml, to) != nil {
+		return fmt.Errorf("Migration error at `%s` (%s): cannot move to `%s`, already exists",
+			NodePath(step), nodeLocation(step), to)
+	}
+
+	if strings.HasPrefix(to, path+TkPathSeparator) {
+		return fmt.Errorf("Migration error at `%s` (%s): cannot move `%s` into itself",
+			NodePath(step), nodeLocation(step), path)
+	}
+
+	parent, name, err := ensureParents(yaml, to, step)
+	if err != nil {
+		return err
+	}
+
+	// Same node moved: its source lines are kept (see YamlLayout)
+	RemoveChildNode(node)
+	node.parent = parent
+	node.name = name
+	parent.children = append(parent.children, node)
+
+	return nil
+}
+
+// migrateSet sets the node at the given path to the step `value` node.
+// The missing parents are created.
+func migrateSet(yaml *YamlNode, step *YamlNode, path string) error {
+	value := TraverseFindChild(step, migrationKeyValue)
+	if value == nil {
+		return fmt.Errorf("Migration error at `%s` (%s): missing `%s`", NodePath(step), nodeLocation(step), migrationKeyValue)
+	}
+
+	node := TraverseFindPath(yaml, path)
+	if node == nil {
+		parent, name, err := ensureParents(yaml, path, step)
+		if err != nil {
+			return err
+		}
+
+		node = NewChildNode(parent)
+		node.name = name
+	}
+
+	name, comments, lineComment := node.name, node.comments, node.lineComment
+	ReplaceNode(node, value)
+	node.name, node.comments, node.lineComment = name, comments, lineComment
+
+	return nil
+}
+
+// migrateRename renames the given node to the step `to` key.
+func migrateRename(step *YamlNode, node *YamlNode) error {
+	to := stepArg(step, migrationKeyTo)
+	if to == "" {
+		return fmt.Errorf("Migration error at `%s` (%s): missing `%s`", NodePath(step), nodeLocation(step), migrationKeyTo)
+	}
+
+	if TraverseFindChild(node.parent, to) != nil {
+		return fmt.Errorf("Migration error at `%s` (%s): cannot rename `%s` to `%s`, already exists",
+			NodePath(step), nodeLocation(step), NodePath(node), to)
+	}
+
+	node.name = to
+
+	return nil
+}
+
+// migrateTransform replaces the step `match` regexp by the step `replace`
+// string in the scalar or list values of the given node. The quotes are kept.
+func migrateTransform(step *YamlNode, node *YamlNode) error {
+	re, err := regexp.Compile(stepArg(step, migrationKeyMatch))
+	if err != nil {
+		return fmt.Errorf("Migration error at `%s` (%s): %s", NodePath(step), nodeLocation(step), err)
+	}
+
+	replace := stepArg(step, migrationKeyReplace)
+
+	// Values (and comments) shared with the copies of the node
+	values := make([]string, len(node.values))
+	var itemComments map[string]*valueComments
+
+	for i, value := range node.values {
+		unquoted := unquote(value)
+		transformed := re.ReplaceAllString(unquoted, replace)
+
+		if unquoted != value {
+			// Same quotes
+			transformed = value[:1] + transformed + value[:1]
+		}
+
+		values[i] = transformed
+
+		if comments := node.itemComments[value]; comments != nil {
+			if itemComments == nil {
+				itemComments = make(map[string]*valueComments)
+			}
+
+			itemComments[transformed] = comments
+		}
+	}
+
+	node.values = values
+	node.itemComments = itemComments
+
+	return nil
+}
+
+// ensureParents returns the parent node of the given path (missing parents
+// are created) and the node name.
+func ensureParents(yaml *YamlNode, path string, step *YamlNode) (*YamlNode, string, error) {
+	names := SplitPath(path)
+	parent := yaml
+
+	for _, name := range names[:len(names)-1] {
+		child := TraverseFindChild(parent, name)
+		if child == nil {
+			child = NewChildNode(parent)
+			child.name = name
+			child.ntype = NodeTypeChildren
+		}
+
+		if child.ntype == NodeTypeChildren && len(child.children) == 0 {
+			// Empty mapping. e.g.: {}
+			child.values = nil
+		}
+
+		if child.ntype != NodeTypeChildren {
+			return nil, "", fmt.Errorf("Migration error at `%s` (%s): `%s` is not a mapping",
+				NodePath(step), nodeLocation(step), NodePath(child))
+		}
+
+		parent = child
+	}
+
+	return parent, names[len(names)-1], nil
+}
+
+// isPathPattern tells if the given path has shell pattern characters. e.g.: services.*.links
+func isPathPattern(path string) bool {
+	for _, name := range SplitPath(path) {
+		if strings.ContainsAny(name, "*?[") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// findMatchingNodes returns the nodes of the given YAML matching the given
+// path pattern. e.g.: services.*.links
+func findMatchingNodes(yaml *YamlNode, pattern string) []*YamlNode {
+	var nodes []*YamlNode
+	collectMatchingNodes(yaml, pattern, len(SplitPath(pattern)), &nodes)
+
+	return nodes
+}
+
+// collectMatchingNodes adds recursively the descendants of the given node
+// matching the given path pattern to the given list.
+// depth Remaining depth of the pattern
+func collectMatchingNodes(node *YamlNode, pattern string, depth int, nodes *[]*YamlNode) {
+	for _, child := range node.children {
+		if depth > 1 {
+			collectMatchingNodes(child, pattern, depth-1, nodes)
+		} else if MatchPath(pattern, NodePath(child)) {
+			*nodes = append(*nodes, child)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package simpleyaml
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func TestMigrateLayout(t *testing.T) {
+	source := "# Compose file\n" +
+		"services:\n" +
+		"    php:\n" +
+		"        image: php:7.4\n" +
+		"        environment:\n" +
+		"            XDEBUG: \"1\"\n" +
+		"        ports:\n" +
+		"            # http\n" +
+		"            - 80\n" +
+		"            - 443 # https\n" +
+		"    web: {image: nginx, restart: always}\n" +
+		"\n" +
+		"# end\n"
+
+	tests := []struct {
+		name      string
+		migration string
+		output    string
+		err       string
+	}{
+		{
+			name:      "node renamed",
+			migration: "version: 1\nsteps:\n  - rename: services.*.ports\n    to: expose\n",
+			output: "# Compose file\n" +
+				"services:\n" +
+				"    php:\n" +
+				"        image: php:7.4\n" +
+				"        environment:\n" +
+				"            XDEBUG: \"1\"\n" +
+				"        expose:\n" +
+				"            # http\n" +
+				"            - 80\n" +
+				"            - 443 # https\n" +
+				"    web: {image: nginx, restart: always}\n" +
+				"x-migration-version: 1\n" +
+				"\n" +
+				"# end\n",
+		},
+		{
+			name:      "node moved",
+			migration: "version: 1\nsteps:\n  - move: services.php.environment.XDEBUG\n    to: services.php.xdebug.enabled\n",
+			output: "# Compose file\n" +
+				"services:\n" +
+				"    php:\n" +
+				"        image: php:7.4\n" +
+				"        environment:\n" +
+				"        ports:\n" +
+				"            # http\n" +
+				"            - 80\n" +
+				"            - 443 # https\n" +
+				"        xdebug:\n" +
+				"            enabled: \"1\"\n" +
+				"    web: {image: nginx, restart: always}\n" +
+				"x-migration-version: 1\n" +
+				"\n" +
+				"# end\n",
+		},
+		{
+			name:      "values transformed",
+			migration: "version: 1\nsteps:\n  - transform: services.php.ports\n    match: '^443$'\n    replace: '8443'\n",
+			output: "# Compose file\n" +
+				"services:\n" +
+				"    php:\n" +
+				"        image: php:7.4\n" +
+				"        environment:\n" +
+				"            XDEBUG: \"1\"\n" +
+				"        ports:\n" +
+				"            # http\n" +
+				"            - 80\n" +
+				"            - 8443 # https\n" +
+				"    web: {image: nginx, restart: always}\n" +
+				"x-migration-version: 1\n" +
+				"\n" +
+				"# end\n",
+		},
+		{
+			name:      "path pattern moved",
+			migration: "version: 1\nsteps:\n  - move: services.*.image\n    to: image\n",
+			err:       "`move` does not support path patterns",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			migration, err := ParseMigration(parseYaml(t, "migration.yml", test.migration))
+
+			var layout *YamlLayout
+			if err == nil {
+				yamls := parseRawYamlStream(t, "source.yml", source)
+				layout = NewLayout(yamls, source)
+				_, _, err = NewMigrator([]*Migration{migration}).Migrate(yamls[0])
+			}
+
+			checkMergeResult(t, mergeTest{output: test.output, err: test.err}, err, func() string {
+				return writeLayout(t, layout)
+			})
+		})
+	}
+}
+
+// writeLayout returns the YAMLs of the given layout written in place.
+func writeLayout(t *testing.T, layout *YamlLayout) string {
+	t.Helper()
+
+	file, err := ioutil.TempFile(t.TempDir(), "output")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	NewWriter(file).WriteLayout(layout)
+
+	content, err := ioutil.ReadFile(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(content)
+}
+
+func TestMigrateLayoutDocuments(t *testing.T) {
+	migration, err := ParseMigration(parseYaml(t, "migration.yml",
+		"version: 1\nsteps:\n  - transform: image\n    match: '7\\.4'\n    replace: '8.1'\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		source string
+		output string
+	}{
+		{
+			name:   "line comments spacing kept",
+			source: "image: php:7.4    # pinned\nports:\n  - 80   # http\n",
+			output: "image: php:8.1    # pinned\nports:\n  - 80   # http\nx-migration-version: 1\n",
+		},
+		{
+			name:   "version before the trailing comments",
+			source: "image: php:7.4\n# end\n---\nimage: php:7.4\n# end 2\n",
+			output: "image: php:8.1\nx-migration-version: 1\n# end\n---\nimage: php:8.1\nx-migration-version: 1\n# end 2\n",
+		},
+		{
+			name:   "CRLF document separators",
+			source: "image: php:7.4\r\n# end\r\n---\r\nimage: php:7.4\r\n",
+			output: "image: php:8.1\nx-migration-version: 1\n# end\r\n---\r\nimage: php:8.1\nx-migration-version: 1\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			yamls := parseRawYamlStream(t, "source.yml", test.source)
+			layout := NewLayout(yamls, test.source)
+
+			for _, yaml := range yamls {
+				if _, _, err := NewMigrator([]*Migration{migration}).Migrate(yaml); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if output := writeLayout(t, layout); output != test.output {
+				t.Fatalf("expected:\n%q\ngot:\n%q", test.output, output)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package simpleyaml
+
+// TkMountDelim separates an input file from its mount path.
+// e.g.: php.prod.yml@services.php
+const TkMountDelim = "@"
+
+// SetMountPath sets the path the root of the YAML of the given index is merged
+// under. e.g.: services.php
+func (ym *YamlMerger) SetMountPath(index int, path string) {
+	ym.mountPaths[index] = path
+}
+
+// mountYaml returns the YAML of the given index under its mount path (if any).
+func (ym *YamlMerger) mountYaml(index int, yaml *YamlNode) *YamlNode {
+	path, isMounted := ym.mountPaths[index]
+	if !isMounted || path == "" {
+		return yaml
+	}
+
+	return MountYaml(yaml, path)
+}
+
+// MountYaml returns a copy of the given YAML with its root children moved
+// under the given path. e.g.: services.php
+// The mount node takes the type of the root (a list root is mounted as a list).
+func MountYaml(yaml *YamlNode, path string) *YamlNode {
+	rootNode := CreateRootNode()
+	rootNode.source = yaml.source
+	rootNode.line = yaml.line
+	rootNode.tag = yaml.tag
+
+	parent := &rootNode
+	for _, name := range SplitPath(path) {
+		parent = NewChildNode(parent)
+		parent.name = name
+		parent.ntype = NodeTypeChildren
+		parent.source = yaml.source
+	}
+
+	if yaml.ntype == NodeTypeChildren {
+		rootNode.values = yaml.values
+	} else {
+		parent.ntype = yaml.ntype
+		parent.values = yaml.values
+		parent.itemComments = yaml.itemComments
+	}
+
+	for _, child := range yaml.children {
+		CopyNode(child, NewChildNode(parent))
+	}
+
+	return &rootNode
+}
+
+// UnmountYaml returns a copy of the given YAML with the children of the node
+// at the given path as root children (reverse of MountYaml).
+func UnmountYaml(yaml *YamlNode, path string) *YamlNode {
+	rootNode := CreateRootNode()
+	rootNode.source = yaml.source
+	rootNode.line = yaml.line
+	rootNode.tag = yaml.tag
+	rootNode.values = yaml.values
+
+	mounted := TraverseFindPath(yaml, path)
+	if mounted == nil {
+		return &rootNode
+	}
+
+	if mounted.ntype != NodeTypeChildren {
+		rootNode.ntype = mounted.ntype
+		rootNode.values = mounted.values
+		rootNode.itemComments = mounted.itemComments
+	}
+
+	for _, child := range mounted.children {
+		CopyNode(child, NewChildNode(&rootNode))
+	}
+
+	return &rootNode
+}
@@ -0,0 +1,58 @@
+package simpleyaml
+
+import (
+	"testing"
+)
+
+func TestMountYaml(t *testing.T) {
+	base := "services:\n  php:\n    image: php\n    ports:\n      - 80\n"
+
+	newMountMerger := func(path string, profiles ...string) func(yamls []*YamlNode) *YamlMerger {
+		return func(yamls []*YamlNode) *YamlMerger {
+			merger := newTestMerger(yamls)
+			merger.SetMountPath(1, path)
+			merger.SetProfiles(profiles...)
+
+			return merger
+		}
+	}
+
+	runMergeTests(t, []mergeTest{
+		{
+			name:   "mapping mounted",
+			inputs: []string{base, "image: php:8\nrestart: always\n"},
+			output: "services:\n  php:\n    image: php:8\n    ports:\n      - 80\n    restart: always\n",
+		},
+	}, newMountMerger("services.php"))
+
+	runMergeTests(t, []mergeTest{
+		{
+			name:   "list mounted",
+			inputs: []string{base, "- 443\n"},
+			output: "services:\n  php:\n    image: php\n    ports:\n      - 80\n      - 443\n",
+		},
+	}, newMountMerger("services.php.ports"))
+
+	runMergeTests(t, []mergeTest{
+		{
+			name:   "profiles folded before mounting",
+			inputs: []string{base, "image: php:8\nimage@prod: php:8-prod\nprofiles:\n  prod:\n    restart: always\n"},
+			output: "services:\n  php:\n    image: php:8-prod\n    ports:\n      - 80\n    restart: always\n",
+		},
+	}, newMountMerger("services.php", "prod"))
+}
+
+func TestStreamMountPath(t *testing.T) {
+	streams := [][]*YamlNode{
+		parseYamlStream(t, "base.yml", "services:\n  php:\n    kind: app\n    image: php\n"),
+		parseYamlStream(t, "php.yml", "kind: app\nimage: php:8\n"),
+	}
+
+	merger := NewStreamMerger(streams, []string{"services.php.kind"}, "nil", newTestMerger)
+	merger.SetMountPath(1, "services.php")
+
+	documents, _, err := merger.Merge()
+	checkMergeResult(t, mergeTest{output: "services:\n  php:\n    kind: app\n    image: php:8\n"}, err, func() string {
+		return writeYamlStream(t, documents...)
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 )
 
 // YamlParser is the struct for parsing YAML files
@@ -14,12 +15,19 @@ type YamlParser struct {
 	readCursor uint   // Current read cursor position (on current line)
 	readBytes  []byte // Current line read bytes
 
-	rootNode      YamlNode
-	currentNode   *YamlNode
-	indentSetting uint // Global indentation setting
+	rootNode    *YamlNode   // Root node of the current document
+	documents   []*YamlNode // Root nodes of the parsed documents
+	currentNode *YamlNode
+	indents     map[*YamlNode]int // Column of the parsed nodes
 
-	prevIndent uint // Previous indentation
-	line       uint // Current line
+	line   uint   // Current line
+	source string // Name of the parsed file
+
+	includeChain []string // Absolute paths of the including files (cycle detection)
+
+	raw         bool     // Keep the comments, do not resolve the includes (in-place rewriting)
+	comments    []string // Pending comment lines, attached to the next node (raw mode)
+	lineComment string   // Comment at the end of the current line (raw mode)
 }
 
 // NewParser returns a new YamlParser to be used for YAML parsing.
@@ -30,15 +38,39 @@ func NewParser(file *os.File) *YamlParser {
 	yp := new(YamlParser)
 
 	yp.reader = reader
+	yp.source = file.Name()
+
+	yp.indents = make(map[*YamlNode]int)
+	yp.startDocument()
+
+	return yp
+}
+
+// NewRawParser returns a new YamlParser keeping the comments and the includes
+// as is, to rewrite the YAML file in place.
+// file YAML file to parse
+func NewRawParser(file *os.File) *YamlParser {
+	yp := NewParser(file)
 
-	yp.rootNode = CreateRootNode()
-	yp.currentNode = &yp.rootNode
+	yp.raw = true
 
 	return yp
 }
 
 // Parse returns a YAML (root node + children nodes) from the input file.
+// Only the first document is returned for multi-documents files (see ParseStream).
 func (yp *YamlParser) Parse() (*YamlNode, error) {
+	documents, err := yp.ParseStream()
+	if err != nil {
+		return nil, err
+	}
+
+	return documents[0], nil
+}
+
+// ParseStream returns the YAMLs (root nodes + children nodes) of the documents
+// of the input file, separated by "---".
+func (yp *YamlParser) ParseStream() ([]*YamlNode, error) {
 	var err error
 
 	yp.line = 1
@@ -53,17 +85,121 @@ func (yp *YamlParser) Parse() (*YamlNode, error) {
 			return nil, err
 		}
 
-		if len(yp.readBytes) > 0 {
+		line := string(yp.readBytes)
+
+		if line == TkDocumentStart || strings.HasPrefix(line, TkDocumentStart+" ") {
+			yp.parseDocumentStart()
+		} else if line == TkDocumentEnd {
+			// Nothing to do, documents are closed by the next start
+		} else if len(yp.readBytes) > 0 {
 			err = yp.parseLine()
 			if err != nil {
 				return nil, err
 			}
+		} else if yp.raw {
+			// Blank line
+			yp.comments = append(yp.comments, "")
 		}
 
 		yp.line++
 	}
 
-	return &yp.rootNode, nil
+	yp.attachTrailingComments()
+
+	var documents []*YamlNode
+
+	for i, document := range yp.documents {
+		if i > 0 && isEmptyDocument(document) {
+			continue
+		}
+
+		if !yp.raw && (document.tag == TagFlat || isFlatFile(yp.source)) {
+			if document.tag == TagFlat {
+				document.tag = ""
+			}
+
+			err = ExpandFlatKeys(document)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if !yp.raw {
+			err = yp.resolveIncludes(document)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		documents = append(documents, document)
+	}
+
+	if len(documents) > 1 && isEmptyDocument(documents[0]) {
+		// Explicit start of the first document
+		documents = documents[1:]
+	}
+
+	return documents, nil
+}
+
+// startDocument creates the root node of a new document.
+func (yp *YamlParser) startDocument() {
+	if yp.rootNode != nil {
+		yp.attachTrailingComments()
+	}
+
+	rootNode := CreateRootNode()
+
+	yp.rootNode = &rootNode
+	yp.rootNode.source = yp.source
+	yp.rootNode.line = yp.line
+	yp.currentNode = yp.rootNode
+	yp.indents[yp.rootNode] = -1
+
+	yp.documents = append(yp.documents, yp.rootNode)
+}
+
+// parseDocumentStart starts a new document from the read bytes.
+// The tag or value following the token is set on the document root node.
+// e.g.: --- !delete
+func (yp *YamlParser) parseDocumentStart() {
+	yp.startDocument()
+
+	if !yp.move(len(TkDocumentStart)) {
+		return
+	}
+
+	yp.consumeSpaces()
+
+	v, err := yp.parseValue("")
+	v = strings.TrimSpace(v)
+	if err != nil || v == "" || strings.HasPrefix(v, TkComment) {
+		return
+	}
+
+	if strings.HasPrefix(v, TkTag) {
+		yp.rootNode.tag = v
+	} else {
+		yp.rootNode.values = []string{v}
+	}
+}
+
+// attachComments attaches the pending comment lines (raw mode) to the given node.
+func (yp *YamlParser) attachComments(node *YamlNode) {
+	node.comments = yp.comments
+	yp.comments = nil
+}
+
+// attachTrailingComments attaches the pending comment lines (raw mode) to the
+// root node of the current document, as its trailing comments.
+func (yp *YamlParser) attachTrailingComments() {
+	yp.rootNode.comments = append(yp.rootNode.comments, yp.comments...)
+	yp.comments = nil
+}
+
+// isEmptyDocument tells if the given document root node has no content.
+func isEmptyDocument(rootNode *YamlNode) bool {
+	return len(rootNode.children) == 0 && len(rootNode.values) == 0 && rootNode.tag == ""
 }
 
 // readLineBytes sets the read bytes until the new line character
@@ -79,6 +215,10 @@ func (yp *YamlParser) readLineBytes() error {
 		b, err := yp.reader.ReadByte()
 
 		if err != nil {
+			if err == io.EOF && len(yp.readBytes) > 0 {
+				// Last line without line ending
+				return nil
+			}
 			return err
 		}
 
@@ -100,54 +240,147 @@ func (yp *YamlParser) readLineBytes() error {
 
 // parseLine constructs the YAML tree by parsing the read bytes.
 func (yp *YamlParser) parseLine() error {
-	var rawIndent = yp.consumeSpaces()
+	var indent = yp.consumeSpaces()
+
+	yp.lineComment = ""
 
-	if yp.pick() == TkComment {
-		// Nothing but comment, skip
+	if yp.pick() == TkComment || yp.pick() == " " {
+		// Nothing but comment or spaces, skip
+		if yp.raw {
+			yp.comments = append(yp.comments, strings.TrimSpace(string(yp.readBytes)))
+		}
 		return nil
 	}
 
-	indent, err := yp.determineIndent(rawIndent)
+	// Check for value type many
+	if yp.isListType() {
+		return yp.parseListItem(indent)
+	}
+
+	// From now on it can only be a new node
 
+	parentNodePtr, err := yp.findParent(indent)
 	if err != nil {
 		return err
 	}
 
-	// Check for value type many
-	if indent >= yp.prevIndent && yp.isListType() {
-		yp.getListValue()
-		return nil
+	return yp.parseEntry(parentNodePtr, indent)
+}
+
+// parseEntry parses the read bytes as a new "key: value" child of the given node.
+// indent Column of the key
+func (yp *YamlParser) parseEntry(parentNode *YamlNode, indent uint) error {
+	yp.currentNode = NewChildNode(parentNode)
+	yp.currentNode.source = yp.source
+	yp.currentNode.line = yp.line
+	yp.indents[yp.currentNode] = int(indent)
+	yp.attachComments(yp.currentNode)
+
+	err := yp.processKey()
+	if err != nil {
+		return err
 	}
 
-	// From now on it can only be a new node
+	if yp.move(len(TkPostKey)) {
+		yp.consumeSpaces()
+		return yp.processValue()
+	}
+
+	return nil
+}
 
-	indentShift := int(indent) - int(yp.prevIndent)
+// findParent returns the parent node of a key at the given column:
+// the closest node (from the current one) with a lower column.
+func (yp *YamlParser) findParent(indent uint) (*YamlNode, error) {
+	parentNode := yp.currentNode
 
-	if indentShift > 1 {
-		return yp.err("Syntax Error! Invalid indent (no parent)")
+	for parentNode.parent != nil && yp.indents[parentNode] >= int(indent) {
+		parentNode = parentNode.parent
 	}
 
-	parentNodePtr := yp.currentNode
+	if parentNode.ntype == NodeTypeList {
+		return nil, yp.err("Syntax Error! Invalid indent (list parent)")
+	}
 
-	if indentShift <= 0 && yp.currentNode.parent != nil {
-		// Rewind to correct node
-		for i := 0; i < (-indentShift)+1; i++ {
-			parentNodePtr = parentNodePtr.parent
-		}
+	c := len(parentNode.children)
+	if c > 0 && yp.indents[parentNode.children[c-1]] != int(indent) {
+		return nil, yp.err("Syntax Error! Invalid indent")
 	}
 
-	yp.currentNode = NewChildNode(parentNodePtr)
+	return parentNode, nil
+}
+
+// parseListItem parses the read bytes as a list item: either a scalar value
+// or the first "key: value" of a mapping item.
+// indent Column of the list item token
+func (yp *YamlParser) parseListItem(indent uint) error {
+	listNode := yp.currentNode
+
+	// Rewind to the list node: lower column, or same column for compact lists
+	// e.g.: containers:
+	//       - name: php
+	for listNode.parent != nil && (yp.indents[listNode] > int(indent) ||
+		(yp.indents[listNode] == int(indent) && isListItem(listNode))) {
+		listNode = listNode.parent
+	}
 
-	yp.processKey()
+	yp.currentNode = listNode
 
-	if yp.move(len(TkPostKey)) {
-		yp.consumeSpaces()
-		yp.processValue()
+	// Skip prefix token
+	yp.move(len(TkPreListValue))
+	itemIndent := indent + uint(len(TkPreListValue)) + yp.consumeSpaces()
+
+	rest := string(yp.readBytes[yp.readCursor:])
+	if !isMappingEntry(rest) && !isFlowMapping(strings.TrimSpace(rest)) {
+		return yp.getListValue()
 	}
 
-	yp.prevIndent = indent
+	listNode.ntype = NodeTypeList
 
-	return nil
+	item := NewChildNode(listNode)
+	item.name = TkListItem
+	item.ntype = NodeTypeChildren
+	item.source = yp.source
+	item.line = yp.line
+	yp.indents[item] = int(indent)
+	yp.attachComments(item)
+
+	if isFlowMapping(strings.TrimSpace(rest)) {
+		yp.currentNode = item
+		return yp.parseFlowMapping(item, strings.TrimSpace(rest))
+	}
+
+	return yp.parseEntry(item, itemIndent)
+}
+
+// isListItem tells if the given node is a mapping item of a list.
+func isListItem(node *YamlNode) bool {
+	return node.parent != nil && node.parent.ntype == NodeTypeList
+}
+
+// isMappingEntry tells if the given list item value is a "key: value" entry.
+func isMappingEntry(value string) bool {
+	var strDelim byte
+	c := len(value)
+
+	for i := 0; i < c; i++ {
+		char := value[i]
+
+		switch {
+		case strDelim != 0:
+			if char == strDelim {
+				strDelim = 0
+			}
+		case char == TkStringDelim1[0] || char == TkStringDelim2[0]:
+			strDelim = char
+		case char == TkComment[0] && i > 0 && value[i-1] == ' ':
+			return false
+		case char == TkPostKey[0] && (i == c-1 || value[i+1] == ' '):
+			return true
+		}
+	}
+
+	return false
 }
 
 // readUntil returns the read bytes until the given character.
@@ -215,6 +448,23 @@ func (yp *YamlParser) processValue() error {
 		return err
 	}
 
+	if strings.HasPrefix(v, TkTag) {
+		split := strings.SplitN(v, " ", 2)
+		yp.currentNode.tag = split[0]
+		v = ""
+		if len(split) > 1 {
+			v = strings.TrimSpace(split[1])
+		}
+	}
+
+	if yp.raw {
+		yp.currentNode.lineComment = yp.lineComment
+	}
+
+	if isFlowMapping(v) {
+		return yp.parseFlowMapping(yp.currentNode, v)
+	}
+
 	if v == "" {
 		yp.currentNode.ntype = NodeTypeChildren
 	} else {
@@ -225,16 +475,100 @@ func (yp *YamlParser) processValue() error {
 	return nil
 }
 
+// parseFlowMapping adds the entries of the given flow mapping as children
+// of the given node. e.g.: {file: common.yml, service: base}
+func (yp *YamlParser) parseFlowMapping(node *YamlNode, mapping string) error {
+	node.ntype = NodeTypeChildren
+
+	if strings.TrimSpace(mapping[len(TkFlowMappingStart):len(mapping)-len(TkFlowMappingEnd)]) == "" {
+		// Keep the empty mapping (not null)
+		node.values = []string{TkFlowMappingStart + TkFlowMappingEnd}
+		return nil
+	}
+
+	entries := splitFlow(mapping[len(TkFlowMappingStart):len(mapping)-len(TkFlowMappingEnd)],
+		TkFlowSeparator)
+
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		split := splitFlow(entry, TkPostKey)
+		k := strings.TrimSpace(split[0])
+		v := strings.TrimSpace(strings.Join(split[1:], TkPostKey))
+
+		if k == "" {
+			return yp.err("Syntax Error! Key can't be null")
+		}
+
+		child := NewChildNode(node)
+		child.name = k
+		child.source = yp.source
+		child.line = yp.line
+
+		if isFlowMapping(v) {
+			err := yp.parseFlowMapping(child, v)
+			if err != nil {
+				return err
+			}
+		} else if v == "" {
+			child.ntype = NodeTypeChildren
+		} else {
+			child.ntype = NodeTypeScalar
+			child.values = []string{v}
+		}
+	}
+
+	return nil
+}
+
+// isFlowMapping tells if the given value is a flow mapping. e.g.: {a: b}
+func isFlowMapping(value string) bool {
+	return strings.HasPrefix(value, TkFlowMappingStart) &&
+		strings.HasSuffix(value, TkFlowMappingEnd)
+}
+
+// splitFlow splits the given flow content by the given separator,
+// ignoring the separators inside strings and nested mappings.
+func splitFlow(str string, sep string) []string {
+	var parts []string
+	var strDelim byte
+	depth := 0
+	start := 0
+
+	for i := 0; i < len(str); i++ {
+		char := str[i]
+
+		switch {
+		case strDelim != 0:
+			if char == strDelim {
+				strDelim = 0
+			}
+		case char == TkStringDelim1[0] || char == TkStringDelim2[0]:
+			strDelim = char
+		case char == TkFlowMappingStart[0]:
+			depth++
+		case char == TkFlowMappingEnd[0]:
+			depth--
+		case depth == 0 && char == sep[0]:
+			parts = append(parts, str[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(parts, str[start:])
+}
+
 // isListType tells if the read bytes is type-of list.
 func (yp *YamlParser) isListType() bool {
 	return yp.equals(TkPreListValue)
 }
 
 // getListValue parses the read bytes to get the current list value.
+// The read cursor must be after the prefix token.
 func (yp *YamlParser) getListValue() error {
-	// Skip prefix token
-	yp.move(len(TkPreListValue))
-
 	v, err := yp.parseValue("")
 
 	if err != nil {
@@ -244,6 +578,15 @@ func (yp *YamlParser) getListValue() error {
 	yp.currentNode.ntype = NodeTypeList
 	yp.appendValue(v)
 
+	if yp.raw && (len(yp.comments) > 0 || yp.lineComment != "") {
+		if yp.currentNode.itemComments == nil {
+			yp.currentNode.itemComments = make(map[string]*valueComments)
+		}
+
+		yp.currentNode.itemComments[v] = &valueComments{comments: yp.comments, lineComment: yp.lineComment}
+		yp.comments = nil
+	}
+
 	return nil
 }
 
@@ -273,6 +616,11 @@ func (yp *YamlParser) parseValue(stopChar string) (string, error) {
 		if !inString && char == TkComment {
 			// Comments are only valid if preceded by a space
 			if yp.read(-1) == " " {
+				// Preceded by its spaces, kept by the in place rewrites
+				trimmed := strings.TrimRight(value, " ")
+				yp.lineComment = value[len(trimmed):] + string(yp.readBytes[yp.readCursor:])
+				value = trimmed
+
 				// Move at the end to skip the comment
 				yp.move(len(yp.readBytes) - 1)
 				break
@@ -365,25 +713,6 @@ func (yp *YamlParser) move(shift int) bool {
 	return true
 }
 
-// determineIndent returns the indent level for the given raw indent.
-func (yp *YamlParser) determineIndent(rawIndent uint) (uint, error) {
-	if rawIndent == 0 {
-		return 0, nil
-	}
-
-	if yp.indentSetting == 0 && rawIndent > 0 {
-		// Initialize indent setting
-		yp.indentSetting = rawIndent
-	}
-
-	if rawIndent%yp.indentSetting > 0 {
-		err := yp.err("Syntax Error! Invalid indent")
-		return 0, err
-	}
-
-	return rawIndent / yp.indentSetting, nil
-}
-
 // err returns an error with the given message and additional parser context.
 func (yp *YamlParser) err(msg string) error {
 	errorMsg := msg +
@@ -0,0 +1,142 @@
+package simpleyaml
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Path Tokens (list items are named by their index. e.g.: containers.0.image)
+const (
+	TkPathSeparator = "."
+	TkPathEscape    = "\\"
+)
+
+// NodePath returns the dotted path of the given node from its root.
+// e.g.: services.php.environment
+func NodePath(node *YamlNode) string {
+	var names []string
+
+	for n := node; n != nil && n.parent != nil; n = n.parent {
+		name := EscapePathName(n.name)
+		if isListItem(n) {
+			name = fmt.Sprint(listItemIndex(n))
+		}
+
+		names = append([]string{name}, names...)
+	}
+
+	return strings.Join(names, TkPathSeparator)
+}
+
+// childNodePath returns the dotted path of a child of the given node.
+func childNodePath(parent *YamlNode, name string) string {
+	if parent.parent == nil {
+		return EscapePathName(name)
+	}
+
+	return NodePath(parent) + TkPathSeparator + EscapePathName(name)
+}
+
+// EscapePathName returns the given node name with its path separators escaped.
+func EscapePathName(name string) string {
+	return strings.Replace(name, TkPathSeparator, TkPathEscape+TkPathSeparator, -1)
+}
+
+// nodeLocation returns the source file and line of the given node.
+// e.g.: tests/input1.yml:12
+func nodeLocation(node *YamlNode) string {
+	if node.line == 0 {
+		return node.source
+	}
+
+	return node.source + ":" + fmt.Sprint(node.line)
+}
+
+// unquotePath returns the given path, its node names unquoted.
+// e.g.: services."db-*" => services.db-*
+func unquotePath(path string) string {
+	names := SplitPath(path)
+	for i, name := range names {
+		names[i] = EscapePathName(unquote(name))
+	}
+
+	return strings.Join(names, TkPathSeparator)
+}
+
+// SplitPath returns the node names of the given dotted path.
+// A dot can be escaped with a backslash. e.g.: labels.traefik\.enable
+func SplitPath(path string) []string {
+	var names []string
+	var name strings.Builder
+
+	c := len(path)
+	for i := 0; i < c; i++ {
+		char := path[i]
+
+		if char == TkPathEscape[0] && i+1 < c && path[i+1] == TkPathSeparator[0] {
+			name.WriteByte(path[i+1])
+			i++
+			continue
+		}
+
+		if char == TkPathSeparator[0] {
+			names = append(names, name.String())
+			name.Reset()
+			continue
+		}
+
+		name.WriteByte(char)
+	}
+
+	if path != "" {
+		names = append(names, name.String())
+	}
+
+	return names
+}
+
+// MatchPath tells if the given dotted path matches the given pattern.
+// Each pattern name is a shell pattern. e.g.: services.*.cap_*
+func MatchPath(pattern string, nodePath string) bool {
+	names := SplitPath(nodePath)
+	patternNames := SplitPath(pattern)
+
+	if len(names) != len(patternNames) {
+		return false
+	}
+
+	for i, patternName := range patternNames {
+		matched, err := matchName(patternName, names[i])
+		if err != nil || !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchName tells if the given node name matches the given shell pattern.
+// Unlike a file name, "*" matches "/" too. e.g.: example.com/*
+func matchName(pattern string, name string) (bool, error) {
+	return path.Match(strings.Replace(pattern, "/", "\x00", -1), strings.Replace(name, "/", "\x00", -1))
+}
+
+// matchPathOrParent tells if the given dotted path or one of its parents
+// matches the given pattern.
+func matchPathOrParent(pattern string, nodePath string) bool {
+	names := SplitPath(nodePath)
+
+	for i := len(names); i > 0; i-- {
+		var escaped []string
+		for _, name := range names[:i] {
+			escaped = append(escaped, EscapePathName(name))
+		}
+
+		if MatchPath(pattern, strings.Join(escaped, TkPathSeparator)) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,175 @@
+package simpleyaml
+
+import (
+	"fmt"
+	"strings"
+)
+
+// refResolver is the struct for resolving the references of a YAML tree
+type refResolver struct {
+	root      *YamlNode
+	resolved  map[*YamlNode]bool
+	resolving []*YamlNode // Nodes being resolved (cycle detection)
+}
+
+// ResolveReferences resolves the references of the given YAML to its own
+// values: ${.path} expressions in values, and !ref tagged nodes.
+// e.g.: image: "${.registry}/php:${.versions.php}"
+func ResolveReferences(yaml *YamlNode) error {
+	rr := &refResolver{root: yaml, resolved: make(map[*YamlNode]bool)}
+
+	return rr.resolveNode(yaml)
+}
+
+// resolveNode resolves recursively the references of the given node
+// (first the ones of the referenced nodes).
+func (rr *refResolver) resolveNode(node *YamlNode) error {
+	if rr.resolved[node] {
+		return nil
+	}
+
+	for i, resolvingNode := range rr.resolving {
+		if resolvingNode == node {
+			var paths []string
+			for _, n := range append(rr.resolving[i:], node) {
+				paths = append(paths, NodePath(n))
+			}
+
+			last := rr.resolving[len(rr.resolving)-1]
+
+			return fmt.Errorf("Reference error at `%s` (%s): cycle detected: %s",
+				NodePath(last), nodeLocation(last), strings.Join(paths, " -> "))
+		}
+	}
+
+	rr.resolving = append(rr.resolving, node)
+
+	err := rr.resolveContent(node)
+	if err != nil {
+		return err
+	}
+
+	rr.resolving = rr.resolving[:len(rr.resolving)-1]
+	rr.resolved[node] = true
+
+	return nil
+}
+
+// resolveContent resolves the references of the given node values and children.
+func (rr *refResolver) resolveContent(node *YamlNode) error {
+	if node.tag == TagRef {
+		return rr.resolveRefTag(node)
+	}
+
+	c := len(node.values)
+
+	for i := 0; i < c; i++ {
+		value, err := rr.resolveString(node, node.values[i])
+		if err != nil {
+			return err
+		}
+
+		if value != node.values[i] {
+			// Values may be shared with the node it was copied from
+			values := make([]string, c)
+			copy(values, node.values)
+			values[i] = value
+			node.values = values
+		}
+	}
+
+	for _, child := range node.children {
+		err := rr.resolveNode(child)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resolveRefTag replaces the given !ref tagged node by a copy of the
+// referenced node. e.g.: healthcheck_url: !ref services.php.url
+func (rr *refResolver) resolveRefTag(node *YamlNode) error {
+	path := ""
+	if node.ntype == NodeTypeScalar {
+		path = strings.TrimPrefix(unquote(node.values[0]), TkPathSeparator)
+	}
+
+	target, err := rr.findTarget(node, path)
+	if err != nil {
+		return err
+	}
+
+	// Detached copy: the target may be a parent of the node
+	copied := new(YamlNode)
+	CopyNode(target, copied)
+
+	name := node.name
+	ReplaceNode(node, copied)
+	node.name = name
+
+	return nil
+}
+
+// resolveString returns the given value of the given node with its ${.path}
+// expressions replaced by the referenced scalar values. "$$" is kept as is.
+func (rr *refResolver) resolveString(node *YamlNode, value string) (string, error) {
+	if !strings.Contains(value, TkVarPrefix+TkVarOpenBrace+TkPathSeparator) {
+		return value, nil
+	}
+
+	var result strings.Builder
+	c := len(value)
+
+	for i := 0; i < c; i++ {
+		if strings.HasPrefix(value[i:], TkVarPrefix+TkVarPrefix) {
+			// Escaped, for the interpolation
+			result.WriteString(value[i : i+2])
+			i++
+			continue
+		}
+
+		if !strings.HasPrefix(value[i:], TkVarPrefix+TkVarOpenBrace+TkPathSeparator) {
+			result.WriteByte(value[i])
+			continue
+		}
+
+		end := findClosingBrace(value, i+2)
+		if end < 0 {
+			return "", fmt.Errorf("Reference error at `%s` (%s): unclosed reference `%s`",
+				NodePath(node), nodeLocation(node), value[i:])
+		}
+
+		target, err := rr.findTarget(node, value[i+3:end])
+		if err != nil {
+			return "", err
+		}
+
+		if target.ntype != NodeTypeScalar {
+			return "", fmt.Errorf("Reference error at `%s` (%s): `%s` is not a scalar",
+				NodePath(node), nodeLocation(node), NodePath(target))
+		}
+
+		result.WriteString(unquote(target.values[0]))
+		i = end
+	}
+
+	return result.String(), nil
+}
+
+// findTarget returns the resolved node at the given path, referenced by the given node.
+func (rr *refResolver) findTarget(node *YamlNode, path string) (*YamlNode, error) {
+	target := TraverseFindPath(rr.root, path)
+	if path == "" || target == nil {
+		return nil, fmt.Errorf("Reference error at `%s` (%s): `%s` not found",
+			NodePath(node), nodeLocation(node), path)
+	}
+
+	err := rr.resolveNode(target)
+	if err != nil {
+		return nil, err
+	}
+
+	return target, nil
+}
@@ -0,0 +1,83 @@
+package simpleyaml
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolveReferences(t *testing.T) {
+	tests := []struct {
+		name   string
+		yaml   string
+		output string
+		err    string
+	}{
+		{
+			name: "value references",
+			yaml: "registry: \"${.host}:5000\"\n" +
+				"host: registry.local\n" +
+				"image: ${.registry}/php:${.versions.php}$$TAG\n" +
+				"versions:\n  php: \"8.1\"\n",
+			output: "registry: \"registry.local:5000\"\n" +
+				"host: registry.local\n" +
+				"image: registry.local:5000/php:8.1$$TAG\n" +
+				"versions:\n  php: \"8.1\"\n",
+		},
+		{
+			name: "node references",
+			yaml: "defaults:\n  restart: always\n  ports:\n    - \"80\"\n" +
+				"services:\n  php:\n    deploy: !ref defaults\n    url: !ref .services.php.host\n    host: php.local\n",
+			output: "defaults:\n  restart: always\n  ports:\n    - \"80\"\n" +
+				"services:\n  php:\n    deploy:\n      restart: always\n      ports:\n        - \"80\"\n" +
+				"    url: php.local\n    host: php.local\n",
+		},
+		{
+			name: "value cycle",
+			yaml: "a: ${.b}\nb: ${.c}\nc: ${.a}\n",
+			err:  "Reference error at `c` (input.yml:3): cycle detected: a -> b -> c -> a",
+		},
+		{
+			name: "node cycle",
+			yaml: "a:\n  b: !ref a\n",
+			err:  "cycle detected: a -> a.b -> a",
+		},
+		{
+			name: "missing path",
+			yaml: "a: ${.missing.key}\n",
+			err:  "Reference error at `a` (input.yml:1): `missing.key` not found",
+		},
+		{
+			name: "not a scalar",
+			yaml: "a: ${.b}\nb:\n  c: 1\n",
+			err:  "`b` is not a scalar",
+		},
+		{
+			name: "unclosed reference",
+			yaml: "a: ${.b\nb: 1\n",
+			err:  "unclosed reference `${.b`",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			yaml := parseYaml(t, "input.yml", test.yaml)
+			trimSources(yaml)
+
+			err := ResolveReferences(yaml)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected error containing %q, got %v", test.err, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if output := writeYamlStream(t, yaml); output != test.output {
+				t.Fatalf("expected:\n%s\ngot:\n%s", test.output, output)
+			}
+		})
+	}
+}
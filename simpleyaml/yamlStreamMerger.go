@@ -0,0 +1,190 @@
+package simpleyaml
+
+import (
+	"strings"
+)
+
+// DefaultIdentityPaths is the default identity of the documents of a stream
+// (Kubernetes resources).
+var DefaultIdentityPaths = []string{"apiVersion", "kind", "metadata.name", "metadata.namespace"}
+
+// YamlStreamMerger is the struct for merging YAML streams (multi-documents files)
+type YamlStreamMerger struct {
+	streams       [][]*YamlNode                       // Streams to merge (documents root nodes)
+	identityPaths []string                            // Paths identifying a document
+	delTk         string                              // Deletion token
+	newMerger     func(yamls []*YamlNode) *YamlMerger // Merger of two matching documents
+	mountPaths    map[int]string                      // Paths the documents roots are merged under [stream index => path]
+}
+
+// NewStreamMerger returns a new YamlStreamMerger to merge X YAML streams.
+// The documents of the overlay streams are matched to the base documents by
+// identity, not by position. Unmatched documents are appended.
+//
+// streams          Streams to merge (usually root nodes from ParseStream)
+// identityPaths    Paths identifying a document. e.g.: DefaultIdentityPaths
+// deletionToken    Token to delete a document. e.g.: nil (--- nil)
+// newMerger        Returns the merger of two matching documents
+func NewStreamMerger(
+	streams [][]*YamlNode,
+	identityPaths []string,
+	deletionToken string,
+	newMerger func(yamls []*YamlNode) *YamlMerger,
+) *YamlStreamMerger {
+	ysm := new(YamlStreamMerger)
+
+	ysm.streams = streams
+	ysm.identityPaths = identityPaths
+	ysm.delTk = deletionToken
+	ysm.newMerger = newMerger
+	ysm.mountPaths = make(map[int]string)
+
+	return ysm
+}
+
+// SetMountPath sets the path the roots of the documents of the stream of the
+// given index are merged under. e.g.: services.php
+func (ysm *YamlStreamMerger) SetMountPath(index int, path string) {
+	ysm.mountPaths[index] = path
+}
+
+// Merge returns the merged documents and the diagnostics found during the merge.
+// The matching documents are merged pairwise, then every merged document is
+// completed once (required placeholders, directives, transforms, references).
+func (ysm *YamlStreamMerger) Merge() ([]*YamlNode, []*MergeDiagnostic, error) {
+	var diagnostics []*MergeDiagnostic
+	var documents []*YamlNode
+	var sources [][]*YamlNode // Documents merged into each document
+
+	streams, err := ysm.prepareStreams()
+	if err != nil {
+		return nil, diagnostics, err
+	}
+
+	// Use the first stream as the merge base
+	for _, document := range streams[0] {
+		if !ysm.isDeletion(document) {
+			documents = append(documents, document)
+			sources = append(sources, []*YamlNode{document})
+		}
+	}
+
+	c := len(streams)
+
+	for i := 1; i < c; i++ {
+		for _, documentX := range streams[i] {
+			index := ysm.findDocument(documents, documentX)
+
+			if ysm.isDeletion(documentX) {
+				if index >= 0 {
+					documents = append(documents[:index], documents[index+1:]...)
+					sources = append(sources[:index], sources[index+1:]...)
+				}
+				continue
+			}
+
+			if index < 0 {
+				documents = append(documents, documentX)
+				sources = append(sources, []*YamlNode{documentX})
+				continue
+			}
+
+			merger := ysm.newMerger([]*YamlNode{documents[index], documentX})
+			merger.partialMerge = true
+
+			merged, mergeDiagnostics, err := merger.Merge()
+			diagnostics = append(diagnostics, mergeDiagnostics...)
+			if err != nil {
+				return nil, diagnostics, err
+			}
+
+			documents[index] = merged
+			sources[index] = append(sources[index], documentX)
+		}
+	}
+
+	for i, document := range documents {
+		err = ysm.newMerger(sources[i]).finalize(document)
+		if err != nil {
+			return nil, diagnostics, err
+		}
+	}
+
+	return documents, diagnostics, nil
+}
+
+// prepareStreams returns the documents of the streams ready to be merged
+// (see YamlMerger.prepareYaml), the deleted documents only mounted.
+func (ysm *YamlStreamMerger) prepareStreams() ([][]*YamlNode, error) {
+	streams := make([][]*YamlNode, len(ysm.streams))
+
+	for i, stream := range ysm.streams {
+		for _, document := range stream {
+			merger := ysm.newMerger([]*YamlNode{document})
+			merger.SetMountPath(0, ysm.mountPaths[i])
+
+			if ysm.isDeletion(document) {
+				document = merger.mountYaml(0, document)
+			} else {
+				var err error
+				document, err = merger.prepareYaml(0, document)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			streams[i] = append(streams[i], document)
+		}
+	}
+
+	return streams, nil
+}
+
+// isDeletion tells if the given document is marked for deletion.
+// e.g.: --- !delete
+func (ysm *YamlStreamMerger) isDeletion(document *YamlNode) bool {
+	if document.tag == TagDelete {
+		return true
+	}
+
+	return ysm.delTk != "" && len(document.values) > 0 && document.values[0] == ysm.delTk
+}
+
+// findDocument returns the index of the document matching the identity of
+// the given document, -1 if not found.
+func (ysm *YamlStreamMerger) findDocument(documents []*YamlNode, document *YamlNode) int {
+	id, hasID := ysm.identity(document)
+	if !hasID {
+		return -1
+	}
+
+	for i, document0 := range documents {
+		id0, hasID0 := ysm.identity(document0)
+		if hasID0 && id0 == id {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// identity returns the identity of the given document.
+// Returns false if none of the identity paths is found.
+func (ysm *YamlStreamMerger) identity(document *YamlNode) (string, bool) {
+	var values []string
+	found := false
+
+	for _, identityPath := range ysm.identityPaths {
+		value := ""
+
+		node := TraverseFindPath(document, identityPath)
+		if node != nil && node.ntype == NodeTypeScalar {
+			value = unquote(node.values[0])
+			found = true
+		}
+
+		values = append(values, value)
+	}
+
+	return strings.Join(values, "\x00"), found
+}
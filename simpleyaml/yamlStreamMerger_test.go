@@ -0,0 +1,112 @@
+package simpleyaml
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStreamMerge(t *testing.T) {
+	newMerger := func(yamls []*YamlNode) *YamlMerger {
+		merger := newTestMerger(yamls)
+		merger.SetTransformer(NewTransformer(nil))
+
+		return merger
+	}
+
+	tests := []struct {
+		mergeTest
+		streams []string
+	}{
+		{
+			mergeTest: mergeTest{
+				name:   "documents matched by identity",
+				output: "kind: A\nmetadata:\n  name: a\nx: 2\n---\nkind: B\nmetadata:\n  name: b\ny: 1\n---\nkind: C\nmetadata:\n  name: c\n",
+			},
+			streams: []string{
+				"kind: A\nmetadata:\n  name: a\nx: 1\n---\nkind: B\nmetadata:\n  name: b\ny: 1\n",
+				"kind: C\nmetadata:\n  name: c\n---\nkind: A\nmetadata:\n  name: a\nx: 2\n",
+			},
+		},
+		{
+			mergeTest: mergeTest{
+				name:   "document deleted",
+				output: "kind: B\nmetadata:\n  name: b\n",
+			},
+			streams: []string{
+				"kind: A\nmetadata:\n  name: a\n---\nkind: B\nmetadata:\n  name: b\n",
+				"--- !delete\nkind: A\nmetadata:\n  name: a\n",
+			},
+		},
+		{
+			mergeTest: mergeTest{
+				name:   "unmatched documents finalized",
+				output: "kind: A\nmetadata:\n  name: a\n---\nkind: B\nmetadata:\n  name: b\nx: 1\nz: ABC\nref: b\n",
+			},
+			streams: []string{
+				"kind: A\nmetadata:\n  name: a\n",
+				"kind: B\nmetadata:\n  name: b\nx: !default 1\nz: !upper abc\nref: ${.metadata.name}\n",
+			},
+		},
+		{
+			mergeTest: mergeTest{
+				name:   "required set by a later stream",
+				output: "kind: A\nmetadata:\n  name: a\npw: secret\nq: 1\n",
+			},
+			streams: []string{
+				"kind: A\nmetadata:\n  name: a\npw: !required\n",
+				"kind: A\nmetadata:\n  name: a\nq: 1\n",
+				"kind: A\nmetadata:\n  name: a\npw: secret\n",
+			},
+		},
+		{
+			mergeTest: mergeTest{
+				name: "required not set",
+				err:  "Required error: 1 value(s) not set by the merged files (stream1.yml, stream2.yml)",
+			},
+			streams: []string{
+				"kind: A\nmetadata:\n  name: a\npw: !required\n",
+				"kind: A\nmetadata:\n  name: a\nq: 1\n",
+			},
+		},
+		{
+			mergeTest: mergeTest{
+				name: "required not set in an unmatched document",
+				err:  "`pw` (stream2.yml:4)",
+			},
+			streams: []string{
+				"kind: A\nmetadata:\n  name: a\n",
+				"kind: B\nmetadata:\n  name: b\npw: !required\n",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var streams [][]*YamlNode
+			for i, stream := range test.streams {
+				streams = append(streams, parseYamlStream(t, "stream"+string(rune('1'+i))+".yml", stream))
+			}
+
+			// Relative sources in the error messages
+			for _, stream := range streams {
+				for _, document := range stream {
+					trimSources(document)
+				}
+			}
+
+			documents, _, err := NewStreamMerger(streams, DefaultIdentityPaths, "nil", newMerger).Merge()
+			checkMergeResult(t, test.mergeTest, err, func() string {
+				return writeYamlStream(t, documents...)
+			})
+		})
+	}
+}
+
+// trimSources removes recursively the directory of the given node sources.
+func trimSources(node *YamlNode) {
+	node.source = node.source[strings.LastIndex(node.source, "/")+1:]
+
+	for _, child := range node.children {
+		trimSources(child)
+	}
+}
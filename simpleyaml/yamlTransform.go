@@ -0,0 +1,172 @@
+package simpleyaml
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Transform Tags
+const (
+	TagEnv    = "!env"    // Value of an environment variable. e.g.: !env DB_PASSWORD
+	TagFile   = "!file"   // Content of a file (relative to the YAML file). e.g.: !file secrets/key.pem
+	TagBase64 = "!base64" // Base64 encoding
+	TagSha256 = "!sha256" // SHA-256 hex digest
+	TagLower  = "!lower"  // Lower case
+	TagUpper  = "!upper"  // Upper case
+)
+
+// Transform returns the scalar value computed from the (unquoted) value of
+// the given node tagged with the transform.
+type Transform func(value string, node *YamlNode) (string, error)
+
+// builtinTransforms is the built-in transforms [tag => transform] (!env apart).
+var builtinTransforms = map[string]Transform{
+	TagFile:   transformFile,
+	TagBase64: transformBase64,
+	TagSha256: transformSha256,
+	TagLower:  transformLower,
+	TagUpper:  transformUpper,
+}
+
+// YamlTransformer is the struct for applying the transform tags of YAML scalars
+type YamlTransformer struct {
+	env        map[string]string    // Variables [name => value] (!env)
+	transforms map[string]Transform // Custom transforms [tag => transform]
+}
+
+// NewTransformer returns a new YamlTransformer to apply transform tags.
+//
+// env Variables to use for !env
+func NewTransformer(env map[string]string) *YamlTransformer {
+	yt := new(YamlTransformer)
+
+	yt.env = env
+	yt.transforms = make(map[string]Transform)
+
+	return yt
+}
+
+// RegisterTransform registers the given custom transform for the given tag.
+// The built-in transform tags cannot be overridden. e.g.: !semver or semver
+func (yt *YamlTransformer) RegisterTransform(tag string, transform Transform) error {
+	if !strings.HasPrefix(tag, TkTag) {
+		tag = TkTag + tag
+	}
+
+	if _, isBuiltin := builtinTransforms[tag]; isBuiltin || tag == TagEnv {
+		return fmt.Errorf("Transform error: %s is a built-in transform", tag)
+	}
+
+	yt.transforms[tag] = transform
+
+	return nil
+}
+
+// Transform applies recursively the transform tags of the given node,
+// then removes them.
+func (yt *YamlTransformer) Transform(node *YamlNode) error {
+	transform, isTransform := builtinTransforms[node.tag]
+	if !isTransform {
+		transform, isTransform = yt.transforms[node.tag]
+	}
+
+	if node.tag == TagEnv {
+		isTransform = true
+		transform = yt.transformEnv
+	}
+
+	if isTransform {
+		if node.ntype != NodeTypeScalar {
+			return fmt.Errorf("Transform error at `%s` (%s): %s only applies to a scalar",
+				NodePath(node), nodeLocation(node), node.tag)
+		}
+
+		value, err := transform(unquote(node.values[0]), node)
+		if err != nil {
+			return fmt.Errorf("Transform error at `%s` (%s): %s", NodePath(node), nodeLocation(node), err)
+		}
+
+		node.values = []string{quoteValue(value, node.values[0])}
+		node.tag = ""
+	}
+
+	for _, child := range node.children {
+		err := yt.Transform(child)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SetTransformer sets the transformer applying the transform tags of the
+// merged YAML (once the references are resolved).
+func (ym *YamlMerger) SetTransformer(transformer *YamlTransformer) {
+	ym.transformer = transformer
+}
+
+// transformEnv returns the value of the given variable.
+func (yt *YamlTransformer) transformEnv(name string, node *YamlNode) (string, error) {
+	value, isSet := yt.env[name]
+	if !isSet {
+		return "", fmt.Errorf("variable `%s` is not set", name)
+	}
+
+	return value, nil
+}
+
+// transformFile returns the content of the given file, relative to the file
+// of the node.
+func transformFile(path string, node *YamlNode) (string, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(node.source), path)
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return string(content), nil
+}
+
+// transformBase64 returns the base64 encoding of the given value.
+func transformBase64(value string, node *YamlNode) (string, error) {
+	return base64.StdEncoding.EncodeToString([]byte(value)), nil
+}
+
+// transformSha256 returns the SHA-256 hex digest of the given value.
+func transformSha256(value string, node *YamlNode) (string, error) {
+	sum := sha256.Sum256([]byte(value))
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// transformLower returns the given value in lower case.
+func transformLower(value string, node *YamlNode) (string, error) {
+	return strings.ToLower(value), nil
+}
+
+// transformUpper returns the given value in upper case.
+func transformUpper(value string, node *YamlNode) (string, error) {
+	return strings.ToUpper(value), nil
+}
+
+// quoteValue returns the given computed value as a raw YAML value: quoted
+// if the original raw value was, or if needed.
+func quoteValue(value string, original string) string {
+	if unquote(original) != original || value == "" ||
+		strings.ContainsAny(value, "\n\r\t\"'#:{}[],&*!|>%@`") ||
+		strings.HasPrefix(value, "-") || strings.TrimSpace(value) != value {
+		return strconv.Quote(value)
+	}
+
+	return value
+}
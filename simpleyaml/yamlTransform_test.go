@@ -0,0 +1,50 @@
+package simpleyaml
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTransform(t *testing.T) {
+	transformer := NewTransformer(map[string]string{"USER": "bob"})
+
+	err := transformer.RegisterTransform("reverse", func(value string, node *YamlNode) (string, error) {
+		runes := []rune(value)
+		for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+			runes[i], runes[j] = runes[j], runes[i]
+		}
+
+		return string(runes), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = transformer.RegisterTransform("!upper", transformLower)
+	if err == nil || !strings.Contains(err.Error(), "!upper is a built-in transform") {
+		t.Fatalf("expected a built-in transform error, got %v", err)
+	}
+
+	runMergeTests(t, []mergeTest{
+		{
+			name:   "transforms applied",
+			inputs: []string{"a: !reverse abc\n", "b: !env USER\nc: !upper x\n"},
+			output: "a: cba\nb: bob\nc: X\n",
+		},
+		{
+			name:   "references resolved first",
+			inputs: []string{"name: app\n", "up: !upper ${.name}\n"},
+			output: "name: app\nup: APP\n",
+		},
+		{
+			name:   "variable not set",
+			inputs: []string{"a: 1\n", "b: !env PASSWORD\n"},
+			err:    "variable `PASSWORD` is not set",
+		},
+	}, func(yamls []*YamlNode) *YamlMerger {
+		merger := newTestMerger(yamls)
+		merger.SetTransformer(transformer)
+
+		return merger
+	})
+}
@@ -1,5 +1,9 @@
 package simpleyaml
 
+import (
+	"strconv"
+)
+
 // TraverseUp returns the parent node
 func TraverseUp(node *YamlNode) *YamlNode {
 	return node.parent
@@ -101,3 +105,40 @@ func TraverseFindSibling(node *YamlNode, name string) *YamlNode {
 
 	return TraverseFindChild(node.parent, name)
 }
+
+// TraverseFindPath returns the descendant node matching the given dotted path
+// (list items are named by their index. e.g.: containers.0.image)
+func TraverseFindPath(node *YamlNode, path string) *YamlNode {
+	child := node
+	names := SplitPath(path)
+
+	for i := 0; i < len(names); i++ {
+		if child.ntype == NodeTypeList {
+			index, err := strconv.Atoi(names[i])
+			if err != nil || index < 0 || index >= len(child.children) {
+				return nil
+			}
+
+			child = child.children[index]
+			continue
+		}
+
+		child = TraverseFindChild(child, names[i])
+		if child == nil {
+			break
+		}
+	}
+
+	return child
+}
+
+// listItemIndex returns the index of the given list item in its list
+func listItemIndex(item *YamlNode) int {
+	for i, child := range item.parent.children {
+		if child == item {
+			return i
+		}
+	}
+
+	return -1
+}
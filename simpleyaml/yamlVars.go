@@ -0,0 +1,141 @@
+package simpleyaml
+
+import (
+	"fmt"
+	"sort"
+)
+
+// VarUsage is the usage of a variable across a YAML tree
+type VarUsage struct {
+	Name       string
+	Paths      []string // Paths of the nodes referencing the variable
+	HasDefault bool     // At least one reference provides a default value
+	Required   bool     // At least one reference requires the variable set. e.g.: ${VAR?error}
+	NonEmpty   bool     // At least one reference requires a non empty value. e.g.: ${VAR:?error}
+	Defined    bool     // The variable has a value in the environment
+	Empty      bool     // The variable is unset or set to an empty value
+}
+
+// Missing tells if the variable is required but has no value, or is required
+// non empty (${VAR:?error}) but is empty.
+func (vu *VarUsage) Missing() bool {
+	return (vu.Required && !vu.Defined) || (vu.NonEmpty && vu.Empty)
+}
+
+// CollectVars returns the variables referenced by the given YAML trees
+// (scalars and list items, !env values included), sorted by name.
+//
+// env Variables available for the interpolation
+func CollectVars(env map[string]string, yamls ...*YamlNode) ([]*VarUsage, error) {
+	usages := make(map[string]*VarUsage)
+
+	for _, yaml := range yamls {
+		err := collectNodeVars(yaml, env, usages)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var list []*VarUsage
+	for _, usage := range usages {
+		list = append(list, usage)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+
+	return list, nil
+}
+
+// UnusedVars returns the sorted names of the given variables which are never
+// referenced by the given usages.
+func UnusedVars(env map[string]string, usages []*VarUsage) []string {
+	used := make(map[string]bool)
+	for _, usage := range usages {
+		used[usage.Name] = true
+	}
+
+	var unused []string
+	for name := range env {
+		if !used[name] {
+			unused = append(unused, name)
+		}
+	}
+
+	sort.Strings(unused)
+
+	return unused
+}
+
+// collectNodeVars collects recursively the variables referenced by the values
+// of the given node.
+func collectNodeVars(node *YamlNode, env map[string]string, usages map[string]*VarUsage) error {
+	path := NodePath(node)
+
+	if node.tag == TagEnv && node.ntype == NodeTypeScalar {
+		// Not required: reported by the transform
+		recordVar(unquote(node.values[0]), path, env, usages)
+	}
+
+	var record func(expr *varExpr) (string, error)
+	record = func(expr *varExpr) (string, error) {
+		if expr.isReference() {
+			// Resolved against the merged YAML
+			return "", nil
+		}
+
+		usage := recordVar(expr.name, path, env, usages)
+
+		switch expr.op {
+		case varOpDefaultEmpty, varOpDefault:
+			usage.HasDefault = true
+		case varOpRequiredEmpty:
+			usage.Required = true
+			usage.NonEmpty = true
+		case varOpRequired:
+			usage.Required = true
+		}
+
+		// Nested expressions (in defaults, errors...)
+		_, err := interpolateString(expr.arg, record)
+
+		return "", err
+	}
+
+	for _, value := range node.values {
+		_, err := interpolateString(value, record)
+		if err != nil {
+			return fmt.Errorf("Interpolation error at `%s` (%s): %s",
+				path, nodeLocation(node), err)
+		}
+	}
+
+	for _, child := range node.children {
+		err := collectNodeVars(child, env, usages)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// recordVar returns the usage of the given variable, adding the given path
+// to its paths.
+func recordVar(name string, path string, env map[string]string, usages map[string]*VarUsage) *VarUsage {
+	usage, found := usages[name]
+	if !found {
+		usage = &VarUsage{Name: name}
+		value, isSet := env[name]
+		usage.Defined = isSet
+		usage.Empty = value == ""
+		usages[name] = usage
+	}
+
+	if len(usage.Paths) == 0 || usage.Paths[len(usage.Paths)-1] != path {
+		usage.Paths = append(usage.Paths, path)
+	}
+
+	return usage
+}
@@ -0,0 +1,73 @@
+package simpleyaml
+
+import (
+	"testing"
+)
+
+func TestCollectVars(t *testing.T) {
+	yaml := parseYaml(t, "input.yml", `services:
+  php:
+    image: php:${PHP_VERSION:-8.1}
+    environment:
+      APP_ENV: ${APP_ENV}
+      APP_SECRET: ${APP_SECRET:?secret required}
+      DB_HOST: ${DB_HOST?}
+      DEBUG: ${DEBUG:+1}
+      PASSWORD: !env DB_PASSWORD
+      REGISTRY: ${.registry}
+`)
+
+	env := map[string]string{"APP_SECRET": "", "DEBUG": "yes"}
+
+	usages, err := CollectVars(env, yaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		name    string
+		path    string
+		missing bool
+	}{
+		{name: "APP_ENV", path: "services.php.environment.APP_ENV"},
+		{name: "APP_SECRET", path: "services.php.environment.APP_SECRET", missing: true},
+		{name: "DB_HOST", path: "services.php.environment.DB_HOST", missing: true},
+		{name: "DB_PASSWORD", path: "services.php.environment.PASSWORD"},
+		{name: "DEBUG", path: "services.php.environment.DEBUG"},
+		{name: "PHP_VERSION", path: "services.php.image"},
+	}
+
+	if len(usages) != len(expected) {
+		t.Fatalf("expected %d variables, got %d", len(expected), len(usages))
+	}
+
+	for i, usage := range usages {
+		if usage.Name != expected[i].name || len(usage.Paths) != 1 || usage.Paths[0] != expected[i].path {
+			t.Fatalf("expected %s at %s, got %s at %v", expected[i].name, expected[i].path, usage.Name, usage.Paths)
+		}
+
+		if usage.Missing() != expected[i].missing {
+			t.Fatalf("expected %s missing: %t", usage.Name, expected[i].missing)
+		}
+	}
+
+	if !usages[5].HasDefault {
+		t.Fatal("expected PHP_VERSION to have a default")
+	}
+}
+
+func TestUnusedVars(t *testing.T) {
+	yaml := parseYaml(t, "input.yml", "a: ${USED}\nb: !env SECRET\n")
+
+	usages, err := CollectVars(map[string]string{}, yaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fileEnv := map[string]string{"USED": "1", "SECRET": "2", "UNUSED": "3"}
+
+	unused := UnusedVars(fileEnv, usages)
+	if len(unused) != 1 || unused[0] != "UNUSED" {
+		t.Fatalf("expected only UNUSED unused, got %v", unused)
+	}
+}
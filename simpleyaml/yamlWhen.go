@@ -0,0 +1,66 @@
+package simpleyaml
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// WhenKey is the top-level key of the conditions of a YAML file.
+// e.g.: when: {env: prod, region: eu-*}
+const WhenKey = "when"
+
+// EvaluateWhen tells if the given YAML applies to the given context
+// (all the conditions of its `when` header match), then removes the header.
+// A condition value is a shell pattern, or a list of patterns (any matches).
+// The context keys are also looked up in upper case. e.g.: env or ENV
+func EvaluateWhen(yaml *YamlNode, context map[string]string) (bool, error) {
+	when := RemoveWhen(yaml)
+	if when == nil {
+		return true, nil
+	}
+
+	matched := true
+
+	for _, condition := range when.children {
+		if condition.ntype != NodeTypeScalar && (condition.ntype != NodeTypeList || len(condition.children) > 0) {
+			return false, fmt.Errorf("When error at `%s` (%s): a condition is a pattern or a list of patterns",
+				NodePath(condition), nodeLocation(condition))
+		}
+
+		name := unquote(condition.name)
+
+		value, isSet := context[name]
+		if !isSet {
+			value, isSet = context[strings.ToUpper(name)]
+		}
+
+		conditionMatched := false
+
+		for _, pattern := range condition.values {
+			isMatch, err := path.Match(unquote(pattern), value)
+			if err != nil {
+				return false, fmt.Errorf("When error at `%s` (%s): %s", NodePath(condition), nodeLocation(condition), err)
+			}
+
+			conditionMatched = conditionMatched || (isSet && isMatch)
+		}
+
+		matched = matched && conditionMatched
+	}
+
+	return matched, nil
+}
+
+// RemoveWhen removes the `when` header of the given YAML, and returns it
+// (nil if none). e.g.: a base YAML always applies
+func RemoveWhen(yaml *YamlNode) *YamlNode {
+	when := TraverseFindChild(yaml, WhenKey)
+	if when == nil || when.ntype != NodeTypeChildren {
+		return nil
+	}
+
+	RemoveChildNode(when)
+
+	return when
+}
@@ -0,0 +1,62 @@
+package simpleyaml
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEvaluateWhen(t *testing.T) {
+	context := map[string]string{"ENV": "prod", "region": "eu-west-1"}
+
+	tests := []struct {
+		name    string
+		input   string
+		matched bool
+		err     string
+	}{
+		{name: "no header", input: "a: 1\n", matched: true},
+		{name: "all matched", input: "when:\n  env: prod\n  region: eu-*\na: 1\n", matched: true},
+		{name: "list matched", input: "when:\n  env:\n    - staging\n    - prod\na: 1\n", matched: true},
+		{name: "not matched", input: "when:\n  env: staging\na: 1\n", matched: false},
+		{name: "not set", input: "when:\n  zone: a\na: 1\n", matched: false},
+		{name: "mapping condition", input: "when:\n  env:\n    x: y\na: 1\n", err: "When error at `when.env`"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			yaml := parseYaml(t, "input.yml", test.input)
+
+			matched, err := EvaluateWhen(yaml, context)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected error containing %q, got %v", test.err, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if matched != test.matched {
+				t.Fatalf("expected matched %v, got %v", test.matched, matched)
+			}
+
+			if TraverseFindChild(yaml, WhenKey) != nil {
+				t.Fatal("expected the `when` header removed")
+			}
+		})
+	}
+}
+
+func TestRemoveWhen(t *testing.T) {
+	yaml := parseYaml(t, "input.yml", "when:\n  env: prod\na: 1\n")
+
+	if when := RemoveWhen(yaml); when == nil || TraverseFindChild(when, "env") == nil {
+		t.Fatal("expected the `when` header returned")
+	}
+
+	if TraverseFindChild(yaml, WhenKey) != nil {
+		t.Fatal("expected the `when` header removed")
+	}
+}
@@ -9,6 +9,8 @@ import (
 type YamlWriter struct {
 	file     *os.File
 	rootNode YamlNode
+	indent   uint   // Spaces per indentation level
+	margin   string // Prefix of every line. e.g.: spaces of a node rewritten in place
 }
 
 // Output settings
@@ -21,6 +23,7 @@ func NewWriter(file *os.File) *YamlWriter {
 	yw := new(YamlWriter)
 
 	yw.file = file
+	yw.indent = OutputIndent
 
 	return yw
 }
@@ -30,32 +33,126 @@ func (yw *YamlWriter) Write(yaml *YamlNode) {
 	yw.rootNode = *yaml
 	// Write directly root children (as the root is "virtual")
 	yw.writeNodeChildren(yaml, 0)
+	yw.writeComments(yaml.comments, 0)
+}
+
+// WriteStream formats the given YAML trees into the output file,
+// as documents separated by "---".
+func (yw *YamlWriter) WriteStream(yamls []*YamlNode) {
+	for i, yaml := range yamls {
+		if i > 0 {
+			yw.file.Write([]byte(TkDocumentStart + "\n"))
+		}
+
+		yw.Write(yaml)
+	}
 }
 
 // writeNode formats the given node (recursively) into the output file.
 // indent Indentation level to use (herited from recursivity)
 func (yw *YamlWriter) writeNode(node *YamlNode, indent uint) {
+	yw.writeNodePrefixed(node, indent, yw.pad(indent))
+}
+
+// pad returns the prefix of the lines at the given indentation level.
+func (yw *YamlWriter) pad(indent uint) string {
+	return yw.margin + strings.Repeat(" ", int(yw.indent*indent))
+}
+
+// writeNodePrefixed formats the given node (recursively) into the output file,
+// with the given line prefix (instead of the indentation).
+// indent Indentation level to use (herited from recursivity)
+func (yw *YamlWriter) writeNodePrefixed(node *YamlNode, indent uint, prefix string) {
 	var data string
 
-	data = strings.Repeat(" ", int(OutputIndent*indent)) +
-		node.name + TkPostKey
+	yw.writeComments(node.comments, indent)
+
+	data = prefix + node.name + TkPostKey
+
+	if node.tag != "" {
+		data += " " + node.tag
+	}
 
 	if node.ntype == NodeTypeScalar {
 		data += " " + node.values[0]
-	} else if node.ntype == NodeTypeList {
+	} else if node.ntype == NodeTypeChildren && len(node.children) == 0 && len(node.values) > 0 {
+		// Empty mapping. e.g.: {}
+		data += " " + node.values[0]
+	}
+
+	if node.lineComment != "" {
+		data += spacedComment(node.lineComment)
+	}
+
+	if node.ntype == NodeTypeList {
+		itemIndent := yw.pad(indent + 1)
+
 		for j := 0; j < len(node.values); j++ {
-			data += "\n" +
-				strings.Repeat(" ", int(OutputIndent*(indent+1))) +
-				TkPreListValue + node.values[j]
+			comments := node.itemComments[node.values[j]]
+			if comments != nil {
+				for _, comment := range comments.comments {
+					if comment != "" {
+						comment = itemIndent + comment
+					}
+
+					data += "\n" + comment
+				}
+			}
+
+			data += "\n" + itemIndent + TkPreListValue + node.values[j]
+
+			if comments != nil && comments.lineComment != "" {
+				data += spacedComment(comments.lineComment)
+			}
 		}
 	}
 	data += "\n"
 
 	yw.file.Write([]byte(data))
 
+	if node.ntype == NodeTypeList {
+		yw.writeListItems(node, indent+1)
+		return
+	}
+
 	yw.writeNodeChildren(node, indent+1)
 }
 
+// writeListItems formats the mapping items of the given list node (recursively):
+// the first key on the list item line, the next ones aligned with it.
+// indent Indentation level to use (herited from recursivity)
+func (yw *YamlWriter) writeListItems(node *YamlNode, indent uint) {
+	for _, item := range node.children {
+		yw.writeListItem(item, indent)
+	}
+}
+
+// writeListItem formats the given mapping item of a list (recursively).
+// indent Indentation level to use (herited from recursivity)
+func (yw *YamlWriter) writeListItem(item *YamlNode, indent uint) {
+	itemPrefix := yw.pad(indent) + TkPreListValue
+
+	yw.writeComments(item.comments, indent)
+
+	if len(item.children) == 0 {
+		yw.file.Write([]byte(itemPrefix + TkFlowMappingStart + TkFlowMappingEnd + "\n"))
+		return
+	}
+
+	// Keys aligned with the first one (whatever the indentation)
+	margin := yw.margin
+	yw.margin = strings.Repeat(" ", len(itemPrefix))
+	defer func() { yw.margin = margin }()
+
+	for i, child := range item.children {
+		if i == 0 {
+			yw.writeNodePrefixed(child, 0, itemPrefix)
+		} else {
+			yw.writeNode(child, 0)
+		}
+	}
+}
+
 // writeNodeChildren formats the child nodes of the given node (recursively).
 // indent Indentation level to use (herited from recursivity)
 func (yw *YamlWriter) writeNodeChildren(node *YamlNode, indent uint) {
@@ -64,6 +161,66 @@ func (yw *YamlWriter) writeNodeChildren(node *YamlNode, indent uint) {
 	for i := 0; i < len(node.children); i++ {
 		childNode = node.children[i]
 
+		if childNode.conflict != nil {
+			yw.writeConflict(childNode.conflict, indent)
+			continue
+		}
+
 		yw.writeNode(childNode, indent)
 	}
 }
+
+// writeConflict formats both sides of the given conflict between markers.
+// The leading comments of both sides are written before the markers if equal.
+// indent Indentation level to use (herited from recursivity)
+func (yw *YamlWriter) writeConflict(conflict *YamlConflict, indent uint) {
+	ours, theirs := conflict.Ours, conflict.Theirs
+
+	if ours != nil && theirs != nil && sameStrings(ours.comments, theirs.comments) {
+		yw.writeComments(ours.comments, indent)
+
+		ours, theirs = withoutComments(ours), withoutComments(theirs)
+	}
+
+	yw.file.Write([]byte(TkConflictOurs + "\n"))
+	if ours != nil {
+		yw.writeNode(ours, indent)
+	}
+
+	yw.file.Write([]byte(TkConflictSep + "\n"))
+	if theirs != nil {
+		yw.writeNode(theirs, indent)
+	}
+
+	yw.file.Write([]byte(TkConflictTheirs + "\n"))
+}
+
+// spacedComment returns the given line comment preceded by its spaces
+// (one by default).
+func spacedComment(comment string) string {
+	if strings.HasPrefix(comment, " ") {
+		return comment
+	}
+
+	return " " + comment
+}
+
+// withoutComments returns a shallow copy of the given node without its leading comments.
+func withoutComments(node *YamlNode) *YamlNode {
+	copied := *node
+	copied.comments = nil
+
+	return &copied
+}
+
+// writeComments formats the given comment lines (blank lines included).
+// indent Indentation level to use (herited from recursivity)
+func (yw *YamlWriter) writeComments(comments []string, indent uint) {
+	for _, comment := range comments {
+		if comment != "" {
+			comment = yw.pad(indent) + comment
+		}
+
+		yw.file.Write([]byte(comment + "\n"))
+	}
+}
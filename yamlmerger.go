@@ -3,6 +3,7 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"strings"
 
@@ -10,56 +11,418 @@ import (
 )
 
 var (
-	inputFlag         = flag.String("i", "", "Input YAML files. e.g: \"file1.yaml file2.yaml [...]\"")
+	inputFlag         = flag.String("i", "", "Input YAML files, optionally merged under a path. e.g: \"file1.yaml file2.yaml@services.php [...]\"")
 	outputFlag        = flag.String("o", "", "[optional] Output YAML file")
 	deletionTokenFlag = flag.String("del-tk", "", "[optional] Deletion token to identify which node(s) to delete")
 	delimPerListFlag  = flag.String("dpl", "", "[optional] Delimiter per list to identify key and value. e.g: \"keyname1:delim1,keyname2:delim2[,...]\"")
 	outForceFlag      = flag.Bool("of", false, "[optional] Overwrite output file if exists")
+	interpolateFlag   = flag.Bool("interpolate", false, "[optional] Interpolate ${VAR} expressions of the merged YAML (Docker Compose rules)")
+	envFileFlag       = flag.String("env-file", "", "[optional] Env files to use for the interpolation. e.g: \"file1.env file2.env [...]\"")
+	conflictFlag      = flag.String("conflict", "", "[optional] Type conflict policy: error (default), overlay-wins, base-wins, wrap-scalar-into-list or warn")
+	conflictPathFlag  = flag.String("conflict-path", "", "[optional] Type conflict policy per path. e.g: \"path1:policy1,services.*.ports:policy2[,...]\"")
+	normalizeFlag     = flag.String("normalize", "", "[optional] Paths accepting either a delimited list or a mapping. e.g: \"services.*.environment:=,path2:delim2[,...]\"")
+	composeFlag       = flag.Bool("compose", false, "[optional] Docker Compose mode (resolve services `extends`)")
+	presetFlag        = flag.String("preset", "", "[optional] Merge rules preset: compose or kubernetes")
+	streamFlag        = flag.Bool("stream", false, "[optional] Merge multi-documents files, matching the documents by identity")
+	identityFlag      = flag.String("identity", "", "[optional] Paths identifying a document in stream mode. e.g: \"apiVersion,kind,metadata.name,metadata.namespace\" (default)")
+	mergeKeysFlag     = flag.String("merge-keys", "", "[optional] Key identifying the mapping items per list. e.g: \"containers:name,listname2:key2[,...]\"")
+	finalFlag         = flag.String("final", "", "[optional] Paths overlays may not override nor delete. e.g: \"networks,services.*.cap_drop[,...]\"")
+	policyFlag        = flag.String("policy", "", "[optional] Access policy file: paths allowed/denied per overlay file")
+	migrationsFlag    = flag.String("migrations", "", "[optional] Migration files (migrate command). e.g: \"001.yml 002.yml [...]\"")
+	profileFlag       = flag.String("profile", "", "[optional] Profiles to fold in (key@profile keys and profiles section). e.g: \"prod,eu[,...]\"")
+	setContextFlag    = flag.String("set-context", "", "[optional] Context of the files `when` conditions (overrides the environment variables). e.g: \"env=prod,region=eu-west-1[,...]\"")
+	markersFlag       = flag.Bool("markers", false, "[optional] Write the conflicts with markers (merge3 command)")
+)
+
+// Access policies of the policy file (-policy)
+var accessPolicies []*simpleyaml.AccessPolicy
+
+// Presets
+const (
+	presetCompose = "compose"
+)
+
+// Commands
+const (
+	commandMerge   = "merge"
+	commandVars    = "vars"
+	commandMerge3  = "merge3"
+	commandMigrate = "migrate"
 )
 
 func main() {
-	flag.Parse()
+	command := parseCommandLine()
+
+	if command == commandMerge3 {
+		merge3Files(flag.Args())
+		return
+	}
+
+	if command == commandMigrate {
+		migrateFiles(flag.Args())
+		return
+	}
 
 	if *inputFlag == "" {
 		flag.Usage()
 		os.Exit(2)
 	}
 
+	mergedYamls := mergeInputFiles()
+
+	if command == commandVars {
+		reportVars(mergedYamls)
+		return
+	}
+
+	if *interpolateFlag || *envFileFlag != "" {
+		interpolateErr := interpolateYamls(mergedYamls)
+		if interpolateErr != nil {
+			fmt.Println(interpolateErr)
+			os.Exit(1)
+		}
+	}
+
+	transformErr := transformYamls(mergedYamls)
+	if transformErr != nil {
+		fmt.Println(transformErr)
+		os.Exit(1)
+	}
+
+	if *outputFlag != "" {
+		writeMergedFile(mergedYamls)
+	}
+
+	fmt.Println("Merge successful.")
+}
+
+// parseCommandLine parses the flags and returns the command to run.
+// Usage: yamlmerger [command] [flags]
+func parseCommandLine() string {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(),
+			"Usage: %s [%s|%s] [flags]\n"+
+				"       %s %s [flags] base ours theirs\n"+
+				"       %s %s -migrations \"files\" file [...]\n",
+			os.Args[0], commandMerge, commandVars, os.Args[0], commandMerge3, os.Args[0], commandMigrate)
+		flag.PrintDefaults()
+	}
+
+	command := commandMerge
+	args := os.Args[1:]
+
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command = args[0]
+		args = args[1:]
+	}
+
+	flag.CommandLine.Parse(args)
+
+	if command != commandMerge && command != commandVars && command != commandMerge3 &&
+		command != commandMigrate {
+		fmt.Println("Unknown command `" + command + "`")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	return command
+}
+
+// mergeInputFiles returns the merged YAML of the input files
+// (the merged documents in stream mode).
+func mergeInputFiles() []*simpleyaml.YamlNode {
 	var inputFiles []*os.File
-	processInputFlag(&inputFiles)
+	var mountPaths []string
+	processInputFlag(&inputFiles, &mountPaths)
 	for i := 0; i < len(inputFiles); i++ {
 		defer inputFiles[i].Close()
 	}
 
-	deletionToken := strings.TrimSpace(*deletionTokenFlag)
-
-	delimiterPerList := strings.TrimSpace(*delimPerListFlag)
-	delimPerListMap := simpleyaml.RawDelimPerListToMap(delimiterPerList)
+	var streams [][]*simpleyaml.YamlNode
+	var streamMountPaths []string
 
-	var yamls []*simpleyaml.YamlNode
-
-	parseErr := parseYamls(inputFiles, &yamls)
+	parseErr := parseYamls(inputFiles, mountPaths, &streams, &streamMountPaths)
 	if parseErr != nil {
 		fmt.Println(parseErr)
 		os.Exit(1)
 	}
 
-	merger := simpleyaml.NewMerger(yamls, deletionToken, delimPerListMap, true)
+	if *policyFlag != "" {
+		policyErr := readPolicyFile(strings.TrimSpace(*policyFlag))
+		if policyErr != nil {
+			fmt.Println(policyErr)
+			os.Exit(1)
+		}
+	}
+
+	var mergedYamls []*simpleyaml.YamlNode
+	var diagnostics []*simpleyaml.MergeDiagnostic
+	var mergeErr error
+
+	if *streamFlag {
+		identityPaths := simpleyaml.DefaultIdentityPaths
+		if *identityFlag != "" {
+			identityPaths = strings.Split(*identityFlag, ",")
+		}
+
+		streamMerger := simpleyaml.NewStreamMerger(streams, identityPaths,
+			strings.TrimSpace(*deletionTokenFlag), newMerger)
+		for i, mountPath := range streamMountPaths {
+			streamMerger.SetMountPath(i, mountPath)
+		}
+
+		mergedYamls, diagnostics, mergeErr = streamMerger.Merge()
+	} else {
+		var yamls []*simpleyaml.YamlNode
+		for _, stream := range streams {
+			yamls = append(yamls, stream[0])
+		}
+
+		merger := newMerger(yamls)
+		for i, mountPath := range streamMountPaths {
+			merger.SetMountPath(i, mountPath)
+		}
+
+		var mergedYaml *simpleyaml.YamlNode
+		mergedYaml, diagnostics, mergeErr = merger.Merge()
+		mergedYamls = []*simpleyaml.YamlNode{mergedYaml}
+	}
+
+	for _, diagnostic := range diagnostics {
+		fmt.Println("Warning: " + diagnostic.String())
+	}
 
-	mergedYaml, mergeErr := merger.Merge()
 	if mergeErr != nil {
 		fmt.Println(mergeErr)
 		os.Exit(1)
 	}
 
+	return mergedYamls
+}
+
+// merge3Files merges the changes of the ours and theirs files from their
+// common base file. Exits with 1 if there are conflicts (git merge driver).
+func merge3Files(filePaths []string) {
+	if len(filePaths) != 3 {
+		fmt.Println("You must specify the base, ours and theirs files")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	streams := make([][]*simpleyaml.YamlNode, len(filePaths))
+
+	for i, filePath := range filePaths {
+		file, err := os.Open(filePath)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		// Comments and includes kept as is
+		streams[i], err = simpleyaml.NewRawParser(file).ParseStream()
+		file.Close()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	mergedYamls, conflicts := simpleyaml.MergeStream3(streams[0], streams[1], streams[2], *markersFlag)
+
 	if *outputFlag != "" {
-		writeMergedFile(mergedYaml)
+		writeMergedFile(mergedYamls)
+	}
+
+	if len(conflicts) > 0 {
+		for _, conflict := range conflicts {
+			if len(mergedYamls) > 1 {
+				fmt.Printf("Conflict at `%s` (document %d)\n", conflict.Path, conflict.Document+1)
+			} else {
+				fmt.Println("Conflict at `" + conflict.Path + "`")
+			}
+		}
+		os.Exit(1)
 	}
 
 	fmt.Println("Merge successful.")
 }
 
-func writeMergedFile(mergedYaml *simpleyaml.YamlNode) {
+// readPolicyFile reads the access policies of the given policy file.
+func readPolicyFile(filePath string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	policyYaml, err := simpleyaml.NewParser(file).Parse()
+	if err != nil {
+		return err
+	}
+
+	accessPolicies, err = simpleyaml.ParseAccessPolicies(policyYaml)
+
+	return err
+}
+
+// migrateFiles upgrades in place the given files with the migrations of the
+// migration files.
+func migrateFiles(filePaths []string) {
+	if *migrationsFlag == "" || len(filePaths) == 0 {
+		fmt.Println("You must specify the migration files and the files to migrate")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var migrations []*simpleyaml.Migration
+
+	for _, migrationPath := range strings.Split(*migrationsFlag, " ") {
+		migrationYaml, err := parseFile(strings.TrimSpace(migrationPath))
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		migration, err := simpleyaml.ParseMigration(migrationYaml[0])
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		migrations = append(migrations, migration)
+	}
+
+	migrator := simpleyaml.NewMigrator(migrations)
+
+	for _, filePath := range filePaths {
+		migrateErr := migrateFile(migrator, filePath)
+		if migrateErr != nil {
+			fmt.Println(migrateErr)
+			os.Exit(1)
+		}
+	}
+}
+
+// migrateFile upgrades in place the given file (each of its documents),
+// rewriting only the lines of the nodes changed.
+func migrateFile(migrator *simpleyaml.YamlMigrator, filePath string) error {
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+
+	yamls, err := simpleyaml.NewRawParser(file).ParseStream()
+	file.Close()
+	if err != nil {
+		return err
+	}
+
+	layout := simpleyaml.NewLayout(yamls, string(content))
+
+	// Written if any document changed (reported from the oldest version)
+	changed := false
+	from, to := -1, 0
+	for _, yaml := range yamls {
+		docFrom, docTo, err := migrator.Migrate(yaml)
+		if err != nil {
+			return err
+		}
+
+		changed = changed || docFrom != docTo
+		if from < 0 || docFrom < from {
+			from = docFrom
+		}
+		if docTo > to {
+			to = docTo
+		}
+	}
+
+	if !changed {
+		fmt.Printf("%s: up to date (version %d)\n", filePath, to)
+		return nil
+	}
+
+	file, err = os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	simpleyaml.NewWriter(file).WriteLayout(layout)
+	fmt.Printf("%s: migrated from version %d to %d\n", filePath, from, to)
+
+	return nil
+}
+
+// parseFile returns the YAMLs of the given file.
+func parseFile(filePath string) ([]*simpleyaml.YamlNode, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return simpleyaml.NewParser(file).ParseStream()
+}
+
+// newMerger returns a new YamlMerger for the given YAMLs, configured by the flags.
+func newMerger(yamls []*simpleyaml.YamlNode) *simpleyaml.YamlMerger {
+	deletionToken := strings.TrimSpace(*deletionTokenFlag)
+
+	delimiterPerList := strings.TrimSpace(*delimPerListFlag)
+	delimPerListMap := simpleyaml.RawDelimPerListToMap(delimiterPerList)
+
+	merger := simpleyaml.NewMerger(yamls, deletionToken, delimPerListMap, true)
+
+	// Path patterns set first: the first matching pattern wins over the preset ones
+	policyErr := processConflictFlags(merger)
+	if policyErr != nil {
+		fmt.Println(policyErr)
+		os.Exit(2)
+	}
+
+	normalizeErr := processNormalizeFlag(merger)
+	if normalizeErr != nil {
+		fmt.Println(normalizeErr)
+		os.Exit(2)
+	}
+
+	if *presetFlag != "" {
+		presetErr := merger.ApplyPreset(strings.TrimSpace(*presetFlag))
+		if presetErr != nil {
+			fmt.Println(presetErr)
+			os.Exit(2)
+		}
+	}
+
+	// Same raw format as the delimiter per list
+	for listName, key := range simpleyaml.RawDelimPerListToMap(strings.TrimSpace(*mergeKeysFlag)) {
+		merger.SetMergeKey(listName, key)
+	}
+
+	if *profileFlag != "" {
+		merger.SetProfiles(strings.Split(strings.Replace(*profileFlag, " ", "", -1), ",")...)
+	}
+
+	for _, policy := range accessPolicies {
+		merger.AddAccessPolicy(policy)
+	}
+
+	if *finalFlag != "" {
+		for _, pattern := range strings.Split(*finalFlag, ",") {
+			merger.SetFinalPath(strings.TrimSpace(pattern))
+		}
+	}
+
+	return merger
+}
+
+func writeMergedFile(mergedYamls []*simpleyaml.YamlNode) {
 	outputFilePath := strings.TrimSpace(*outputFlag)
 
 	if !*outForceFlag {
@@ -78,10 +441,12 @@ func writeMergedFile(mergedYaml *simpleyaml.YamlNode) {
 	defer outputFile.Close()
 
 	writer := simpleyaml.NewWriter(outputFile)
-	writer.Write(mergedYaml)
+	writer.WriteStream(mergedYamls)
 }
 
-func processInputFlag(inputFiles *[]*os.File) {
+// processInputFlag opens the input files, and returns their mount paths
+// ("" if none). e.g.: php.prod.yml@services.php
+func processInputFlag(inputFiles *[]*os.File, mountPaths *[]string) {
 	inputFilesPaths := strings.Split(*inputFlag, " ")
 
 	if len(inputFilesPaths) < 2 {
@@ -91,10 +456,17 @@ func processInputFlag(inputFiles *[]*os.File) {
 
 	c := len(inputFilesPaths)
 	*inputFiles = make([]*os.File, c)
+	*mountPaths = make([]string, c)
 
 	for i := 0; i < c; i++ {
 		filePath := strings.TrimSpace(inputFilesPaths[i])
 
+		delimPos := strings.LastIndex(filePath, simpleyaml.TkMountDelim)
+		if _, statErr := os.Stat(filePath); os.IsNotExist(statErr) && delimPos > 0 {
+			(*mountPaths)[i] = filePath[delimPos+len(simpleyaml.TkMountDelim):]
+			filePath = filePath[:delimPos]
+		}
+
 		file, err := os.Open(filePath)
 		if err != nil {
 			fmt.Println(err)
@@ -105,19 +477,326 @@ func processInputFlag(inputFiles *[]*os.File) {
 	}
 }
 
-func parseYamls(files []*os.File, yamls *[]*simpleyaml.YamlNode) error {
+// processConflictFlags sets the type conflict policies of the given merger.
+func processConflictFlags(merger *simpleyaml.YamlMerger) error {
+	if *conflictFlag != "" {
+		policy, err := simpleyaml.ParseConflictPolicy(strings.TrimSpace(*conflictFlag))
+		if err != nil {
+			return err
+		}
+
+		merger.SetConflictPolicy(policy)
+	}
+
+	pairs, err := splitRawPathPairs(*conflictPathFlag)
+	if err != nil {
+		return err
+	}
+
+	for _, pair := range pairs {
+		policy, err := simpleyaml.ParseConflictPolicy(strings.TrimSpace(pair[1]))
+		if err != nil {
+			return err
+		}
+
+		merger.SetPathConflictPolicy(pair[0], policy)
+	}
+
+	return nil
+}
+
+// processNormalizeFlag sets the normalized paths of the given merger.
+func processNormalizeFlag(merger *simpleyaml.YamlMerger) error {
+	pairs, err := splitRawPathPairs(*normalizeFlag)
+	if err != nil {
+		return err
+	}
+
+	for _, pair := range pairs {
+		merger.SetNormalizedPath(pair[0], pair[1])
+	}
+
+	return nil
+}
+
+// splitRawPathPairs returns the ordered [path pattern, value] pairs of the
+// given raw format: path1:value1,path2:value2[,...]
+func splitRawPathPairs(raw string) ([][2]string, error) {
+	var pairs [][2]string
+
+	if raw == "" {
+		return pairs, nil
+	}
+
+	// Keep the order: the first matching pattern wins
+	for _, rawPair := range strings.Split(raw, ",") {
+		split := strings.SplitN(rawPair, ":", 2)
+		if len(split) < 2 || split[1] == "" {
+			return nil, fmt.Errorf("Malformed path option `%s`", rawPair)
+		}
+
+		pairs = append(pairs, [2]string{strings.TrimSpace(split[0]), split[1]})
+	}
+
+	return pairs, nil
+}
+
+// parseYamls parses the documents of the files meeting their `when` conditions,
+// and returns their mount paths (mounted by the mergers, once their profiles folded in).
+func parseYamls(files []*os.File, mountPaths []string, streams *[][]*simpleyaml.YamlNode,
+	streamMountPaths *[]string) error {
 	c := len(files)
+	context, err := readContext()
+	if err != nil {
+		return err
+	}
 
 	for i := 0; i < c; i++ {
 		parser := simpleyaml.NewParser(files[i])
 
-		yaml, err := parser.Parse()
+		yamls, err := parser.ParseStream()
+		if err != nil {
+			return err
+		}
+
+		if i == 0 {
+			// The base file always applies
+			for _, yaml := range yamls {
+				simpleyaml.RemoveWhen(yaml)
+			}
+		} else {
+			yamls, err = filterYamls(files[i].Name(), yamls, context)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(yamls) == 0 {
+			fmt.Fprintln(os.Stderr, "Skipping "+files[i].Name()+" (`when` conditions not met)")
+			continue
+		}
+
+		if *composeFlag || *presetFlag == presetCompose {
+			for j, yaml := range yamls {
+				yamls[j], err = resolveComposeExtends(yaml, mountPaths[i])
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		*streams = append(*streams, yamls)
+		*streamMountPaths = append(*streamMountPaths, mountPaths[i])
+	}
+
+	return nil
+}
+
+// filterYamls returns the documents of the given overlay file meeting their
+// `when` conditions (only the first one is merged out of the -stream mode).
+func filterYamls(fileName string, yamls []*simpleyaml.YamlNode, context map[string]string) (
+	[]*simpleyaml.YamlNode, error) {
+	if !*streamFlag {
+		yamls = yamls[:1]
+	}
+
+	var matchedYamls []*simpleyaml.YamlNode
+	var skipped []int
+
+	for j, yaml := range yamls {
+		matched, err := simpleyaml.EvaluateWhen(yaml, context)
+		if err != nil {
+			return nil, err
+		}
+
+		if matched {
+			matchedYamls = append(matchedYamls, yaml)
+		} else {
+			skipped = append(skipped, j+1)
+		}
+	}
+
+	if len(matchedYamls) > 0 {
+		// The whole file skipped is reported by the caller
+		for _, number := range skipped {
+			fmt.Fprintf(os.Stderr, "Skipping the document %d of %s (`when` conditions not met)\n", number, fileName)
+		}
+	}
+
+	return matchedYamls, nil
+}
+
+// resolveComposeExtends applies the services extended by the services of the
+// given YAML, resolved under its mount path (if any).
+func resolveComposeExtends(yaml *simpleyaml.YamlNode, mountPath string) (*simpleyaml.YamlNode, error) {
+	if mountPath == "" {
+		return yaml, simpleyaml.ResolveComposeExtends(yaml)
+	}
+
+	mounted := simpleyaml.MountYaml(yaml, mountPath)
+
+	err := simpleyaml.ResolveComposeExtends(mounted)
+	if err != nil {
+		return nil, err
+	}
+
+	return simpleyaml.UnmountYaml(mounted, mountPath), nil
+}
+
+// readContext returns the context of the files `when` conditions:
+// the environment variables overridden by the -set-context values.
+func readContext() (map[string]string, error) {
+	context := simpleyaml.EnvironToMap(os.Environ())
+
+	if *setContextFlag == "" {
+		return context, nil
+	}
+
+	for _, pair := range strings.Split(*setContextFlag, ",") {
+		split := strings.SplitN(pair, "=", 2)
+		if len(split) < 2 || strings.TrimSpace(split[0]) == "" {
+			return nil, fmt.Errorf("Malformed context option `%s`", pair)
+		}
+
+		context[strings.TrimSpace(split[0])] = strings.TrimSpace(split[1])
+	}
+
+	return context, nil
+}
+
+// interpolateYamls resolves the ${VAR} expressions of the given YAMLs with the
+// env files variables, overridden by the process environment ones.
+func interpolateYamls(yamls []*simpleyaml.YamlNode) error {
+	env, err := readEnv()
+	if err != nil {
+		return err
+	}
+
+	interpolator := simpleyaml.NewInterpolator(env)
+
+	for _, yaml := range yamls {
+		err = interpolator.Interpolate(yaml)
 		if err != nil {
 			return err
 		}
+	}
 
-		*yamls = append(*yamls, yaml)
+	return nil
+}
+
+// transformYamls applies the transform tags of the given YAMLs (once
+// interpolated), !env using the env files variables overridden by the process
+// environment ones.
+func transformYamls(yamls []*simpleyaml.YamlNode) error {
+	env, err := readEnv()
+	if err != nil {
+		return err
+	}
+
+	transformer := simpleyaml.NewTransformer(env)
+
+	for _, yaml := range yamls {
+		err = transformer.Transform(yaml)
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
+
+// readEnv returns the variables of the env files, overridden by the
+// environment variables.
+func readEnv() (map[string]string, error) {
+	env, err := readEnvFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range simpleyaml.EnvironToMap(os.Environ()) {
+		env[k] = v
+	}
+
+	return env, nil
+}
+
+// reportVars prints the ${VAR} references of the given YAMLs and the unused
+// env files variables. Exits with 1 if a required variable is missing.
+func reportVars(yamls []*simpleyaml.YamlNode) {
+	fileEnv, err := readEnvFiles()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	env := simpleyaml.EnvironToMap(os.Environ())
+	for k, v := range fileEnv {
+		if _, isSet := env[k]; !isSet {
+			env[k] = v
+		}
+	}
+
+	usages, err := simpleyaml.CollectVars(env, yamls...)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	missing := 0
+
+	for _, usage := range usages {
+		status := "defined"
+		if usage.Missing() {
+			status = "MISSING"
+			missing++
+		} else if !usage.Defined {
+			status = "undefined"
+		}
+
+		if usage.HasDefault {
+			status += ", has default"
+		}
+
+		fmt.Printf("%s (%s)\n", usage.Name, status)
+		for _, path := range usage.Paths {
+			fmt.Println("  - " + path)
+		}
+	}
+
+	unused := simpleyaml.UnusedVars(fileEnv, usages)
+	if len(unused) > 0 {
+		fmt.Println("Unused env file variables:")
+		for _, name := range unused {
+			fmt.Println("  - " + name)
+		}
+	}
+
+	if missing > 0 {
+		fmt.Printf("%d required variable(s) missing.\n", missing)
+		os.Exit(1)
+	}
+}
+
+// readEnvFiles returns the variables of the env files (the last file wins).
+func readEnvFiles() (map[string]string, error) {
+	env := make(map[string]string)
+
+	for _, filePath := range strings.Fields(*envFileFlag) {
+		file, err := os.Open(filePath)
+		if err != nil {
+			return nil, err
+		}
+
+		fileEnv, err := simpleyaml.ReadEnvFile(file)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for k, v := range fileEnv {
+			env[k] = v
+		}
+	}
+
+	return env, nil
+}